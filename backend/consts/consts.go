@@ -5,9 +5,7 @@ import (
 	"path/filepath"
 )
 
-var AddonsBasePath string
 var GamePath string
-var MapListFilePath string
 var Version = "Dev"
 
 func init() {
@@ -27,6 +25,4 @@ func init() {
 			GamePath = "/left4dead2"
 		}
 	}
-	AddonsBasePath = filepath.Join(GamePath, "addons")
-	MapListFilePath = filepath.Join(AddonsBasePath, "maplist.txt")
 }
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"l4d2-manager-next/logic"
 	"net/http"
 
@@ -8,12 +9,10 @@ import (
 )
 
 func GetAdmins(c *gin.Context) {
-	admins, err := logic.ParseAdminsSimple()
+	admins, err := logic.ListAdmins(getServer(c))
 	if err != nil {
-		// Return 200 with error message in body if file missing, or 500
-		// Requirement says: "if file not exists, prompt to enable sourcemod"
-		// ParseAdminsSimple returns error for this.
-		if err.Error() == "SourceMod 未启用或配置文件不存在" {
+		// 配置目录不存在时提示先启用 SourceMod
+		if errors.Is(err, logic.ErrSourceModMissing) {
 			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
 			return
 		}
@@ -23,31 +22,39 @@ func GetAdmins(c *gin.Context) {
 	c.JSON(http.StatusOK, admins)
 }
 
-type AddAdminRequest struct {
-	SteamID string `json:"steamid" binding:"required"`
-	Remark  string `json:"remark"`
-}
-
+// AddAdmin 添加管理员，未指定权限和组时与旧版一样授予 99:z
 func AddAdmin(c *gin.Context) {
-	// Check Permission
-	role, _ := c.Get("role")
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足，仅管理员可操作"})
+	var req logic.AdminUser
+	if err := c.ShouldBindJSON(&req); err != nil || req.SteamID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
 		return
 	}
+	if req.Flags == "" && len(req.Groups) == 0 {
+		req.Flags = "z"
+		req.Immunity = 99
+	}
 
-	var req AddAdminRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	server := getServer(c)
+	if err := logic.AddAdmin(server, req); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondAdminsChanged(c, server, "添加成功")
+}
+
+func UpdateAdmin(c *gin.Context) {
+	var req logic.AdminUser
+	if err := c.ShouldBindJSON(&req); err != nil || req.SteamID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
 		return
 	}
 
-	if err := logic.AddAdmin(req.SteamID, req.Remark); err != nil {
+	server := getServer(c)
+	if err := logic.UpdateAdmin(server, req); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
-
-	c.JSON(http.StatusOK, gin.H{"message": "添加成功"})
+	respondAdminsChanged(c, server, "保存成功")
 }
 
 type DeleteAdminRequest struct {
@@ -55,23 +62,71 @@ type DeleteAdminRequest struct {
 }
 
 func DeleteAdmin(c *gin.Context) {
-	// Check Permission
-	role, _ := c.Get("role")
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足，仅管理员可操作"})
+	var req DeleteAdminRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
 		return
 	}
 
-	var req DeleteAdminRequest
+	server := getServer(c)
+	if err := logic.DeleteAdmin(server, req.SteamID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondAdminsChanged(c, server, "删除成功")
+}
+
+func GetAdminGroups(c *gin.Context) {
+	groups, err := logic.ListAdminGroups(getServer(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, groups)
+}
+
+func SaveAdminGroup(c *gin.Context) {
+	var req logic.AdminGroup
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
 		return
 	}
 
-	if err := logic.DeleteAdmin(req.SteamID); err != nil {
+	server := getServer(c)
+	if err := logic.SaveAdminGroup(server, req); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	respondAdminsChanged(c, server, "保存成功")
+}
+
+type DeleteAdminGroupRequest struct {
+	Name string `json:"name" binding:"required"`
+}
 
-	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+func DeleteAdminGroup(c *gin.Context) {
+	var req DeleteAdminGroupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	server := getServer(c)
+	if err := logic.DeleteAdminGroup(server, req.Name); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	respondAdminsChanged(c, server, "删除成功")
+}
+
+// respondAdminsChanged 配置修改后执行 sm_reloadadmins，重载失败不影响已保存的修改
+func respondAdminsChanged(c *gin.Context, server *logic.GameServer, message string) {
+	if err := logic.ReloadAdmins(server); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": message,
+			"warning": "重载管理员失败，将在地图切换或服务器重启后生效: " + err.Error(),
+		})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": message})
 }
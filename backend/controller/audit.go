@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"l4d2-manager-next/logic"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultAuditLimit = 100
+	maxAuditLimit     = 1000
+)
+
+// ListAudit 查询审计日志
+// 参数: actor, action (支持 "maps.*"), start/end (Unix 秒或 RFC3339), offset, limit
+// 返回按时间倒序的记录，has_more 表示下一页还有数据
+func ListAudit(c *gin.Context) {
+	filter := logic.AuditFilter{
+		Actor:  c.PostForm("actor"),
+		Action: c.PostForm("action"),
+		Limit:  defaultAuditLimit,
+	}
+
+	var err error
+	if filter.Since, err = parseAuditTime(c.PostForm("start")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的开始时间"})
+		return
+	}
+	if filter.Until, err = parseAuditTime(c.PostForm("end")); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的结束时间"})
+		return
+	}
+	if value := c.PostForm("offset"); value != "" {
+		if filter.Offset, err = strconv.Atoi(value); err != nil || filter.Offset < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的offset"})
+			return
+		}
+	}
+	if value := c.PostForm("limit"); value != "" {
+		if filter.Limit, err = strconv.Atoi(value); err != nil || filter.Limit <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的limit"})
+			return
+		}
+		if filter.Limit > maxAuditLimit {
+			filter.Limit = maxAuditLimit
+		}
+	}
+
+	entries, hasMore, err := logic.QueryAudit(filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "读取审计日志失败: " + err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"has_more": hasMore,
+		"entries":  entries,
+	})
+}
+
+func parseAuditTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -3,22 +3,45 @@ package controller
 import (
 	"fmt"
 	"log"
+	"strings"
 	"time"
 
 	"l4d2-manager-next/logic"
 
 	"github.com/duke-git/lancet/v2/convertor"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
 func Auth(c *gin.Context) {
 	// 中间件已经验证密码
 	role, _ := c.Get("role")
-	c.JSON(200, gin.H{
-		"status": "ok",
-		"role":   role,
-	})
+	res := gin.H{
+		"status":      "ok",
+		"role":        role,
+		"username":    c.GetString("username"),
+		"permissions": c.GetStringSlice("permissions"),
+	}
+
+	// 使用用户名密码登录时签发会话令牌，后续请求使用令牌即可
+	if c.GetBool("authByPassword") {
+		privateKey := c.MustGet("privateKey").([]byte)
+		token, expiresAt, err := logic.IssueSessionToken(privateKey, c.GetString("username"))
+		if err != nil {
+			c.String(500, "生成会话令牌失败: %v", err)
+			return
+		}
+		res["token"] = token
+		res["expires_at"] = expiresAt
+	}
+	c.JSON(200, res)
+}
+
+// actorName 当前操作者，临时授权码以 guest:<授权码ID> 表示
+func actorName(c *gin.Context) string {
+	if username := c.GetString("username"); username != "" {
+		return username
+	}
+	return logic.RoleGuest + ":" + c.GetString("guestTokenId")
 }
 
 func GetTempAuthCode(c *gin.Context) {
@@ -35,12 +58,34 @@ func GetTempAuthCode(c *gin.Context) {
 			expired = int(ex)
 		}
 	}
-	claims := jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Duration(expired) * time.Hour)),
+
+	// 可选：为授权码指定权限列表，不能超出签发者自身的权限
+	var permissions []string
+	for _, value := range c.PostFormArray("permissions") {
+		for _, perm := range strings.Split(value, ",") {
+			perm = strings.TrimSpace(perm)
+			if perm == "" {
+				continue
+			}
+			if !logic.IsValidPermission(perm) {
+				c.String(400, "未知的权限: %s", perm)
+				return
+			}
+			if !logic.HasPermission(c.GetStringSlice("permissions"), perm) {
+				c.String(403, "不能授予自己没有的权限: %s", perm)
+				return
+			}
+			permissions = append(permissions, perm)
+		}
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(privateKey)
+	issuer := actorName(c)
+	label := strings.TrimSpace(c.PostForm("label"))
+	if label == "" {
+		label = "临时授权码"
+	}
+
+	tokenString, _, err := logic.IssueGuestToken(privateKey.([]byte), label, issuer, c.ClientIP(), permissions, time.Duration(expired)*time.Hour)
 	if err != nil {
 		c.String(500, "生成授权码失败: %v", err)
 		return
@@ -48,6 +93,24 @@ func GetTempAuthCode(c *gin.Context) {
 	c.String(200, tokenString)
 }
 
+func ListGuestTokens(c *gin.Context) {
+	c.JSON(200, logic.ListGuestTokens())
+}
+
+func RevokeGuestToken(c *gin.Context) {
+	id := c.PostForm("id")
+	if id == "" {
+		c.JSON(400, gin.H{"error": "授权码ID不能为空"})
+		return
+	}
+
+	if err := logic.RevokeGuestToken(id, actorName(c)); err != nil {
+		c.JSON(400, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(200, gin.H{"message": "撤销成功"})
+}
+
 func GetSelfServiceStatus(c *gin.Context) {
 	config := logic.GetSelfServiceConfig()
 	inCooldown := false
@@ -103,14 +166,8 @@ func GenerateSelfServiceCode(c *gin.Context) {
 	}
 
 	// 生成 1 小时有效期的 token
-	now := time.Now()
-	expireTime := now.Add(time.Hour)
-	claims := jwt.RegisteredClaims{
-		ExpiresAt: jwt.NewNumericDate(expireTime),
-	}
-
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(privateKey) // privateKey 应该是 []byte
+	label := "自助授权 " + c.ClientIP()
+	tokenString, record, err := logic.IssueGuestToken(privateKey.([]byte), label, logic.SelfServiceIssuer, c.ClientIP(), nil, time.Hour)
 	if err != nil {
 		c.JSON(500, gin.H{"error": fmt.Sprintf("生成授权码失败: %v", err)})
 		return
@@ -122,7 +179,7 @@ func GenerateSelfServiceCode(c *gin.Context) {
 	}
 
 	timeFormat := "2006-01-02 15:04:05"
-	log.Printf("[自助授权] IP: %s 获取了授权码, 有效期: %s - %s", c.ClientIP(), now.Format(timeFormat), expireTime.Format(timeFormat))
+	log.Printf("[自助授权] IP: %s 获取了授权码 %s, 有效期: %s - %s", c.ClientIP(), record.ID, record.CreatedAt.Format(timeFormat), record.ExpiresAt.Format(timeFormat))
 
 	c.JSON(200, gin.H{
 		"code": tokenString,
@@ -130,12 +187,6 @@ func GenerateSelfServiceCode(c *gin.Context) {
 }
 
 func SetSelfServiceConfig(c *gin.Context) {
-	role, _ := c.Get("role")
-	if role != "admin" {
-		c.JSON(403, gin.H{"error": "需要管理员权限"})
-		return
-	}
-
 	var req struct {
 		Enable bool `json:"enable"`
 	}
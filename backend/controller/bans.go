@@ -0,0 +1,78 @@
+package controller
+
+import (
+	"l4d2-manager-next/logic"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListBans(c *gin.Context) {
+	bans, err := logic.ListBans(getServer(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, bans)
+}
+
+// BanUser 封禁玩家
+// steamId 或 userId 封禁玩家（SteamID 对离线玩家同样有效），ip 封禁 IP
+// minutes 为空或 0 表示永久，kick 为 true 时同时踢出
+func BanUser(c *gin.Context) {
+	req := logic.BanRequest{
+		Name:     c.PostForm("name"),
+		Reason:   c.PostForm("reason"),
+		Kick:     c.PostForm("kick") == "true",
+		IssuedBy: actorName(c),
+	}
+
+	if ip := c.PostForm("ip"); ip != "" {
+		req.Type = logic.BanTypeIP
+		req.Target = ip
+	} else if steamId := c.PostForm("steamId"); steamId != "" {
+		req.Type = logic.BanTypeSteamID
+		req.Target = steamId
+	} else if userId := c.PostForm("userId"); userId != "" {
+		req.Type = logic.BanTypeSteamID
+		req.Target = userId
+	} else {
+		c.String(http.StatusBadRequest, "SteamID、用户ID或IP不能为空")
+		return
+	}
+
+	if minutes := c.PostForm("minutes"); minutes != "" {
+		value, err := strconv.ParseFloat(minutes, 64)
+		if err != nil || math.IsNaN(value) || math.IsInf(value, 0) || value < 0 {
+			c.String(http.StatusBadRequest, "无效的封禁时长")
+			return
+		}
+		if value > logic.MaxBanMinutes {
+			c.String(http.StatusBadRequest, "封禁时长不能超过 %d 分钟，更长请使用永久封禁", logic.MaxBanMinutes)
+			return
+		}
+		req.Minutes = value
+	}
+
+	if err := logic.BanPlayer(getServer(c), req); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "用户封禁成功")
+}
+
+// Unban 解除封禁，type 为 steamid 或 ip
+func Unban(c *gin.Context) {
+	banType := c.PostForm("type")
+	if banType == "" {
+		banType = logic.BanTypeSteamID
+	}
+
+	if err := logic.Unban(getServer(c), banType, c.PostForm("target")); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "解封成功")
+}
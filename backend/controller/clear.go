@@ -1,7 +1,7 @@
 package controller
 
 import (
-	"l4d2-manager-next/consts"
+	"l4d2-manager-next/logic"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,7 +14,8 @@ func Clear(c *gin.Context) {
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	fileBytes, err := os.ReadFile(consts.MapListFilePath)
+	server := getServer(c)
+	fileBytes, err := os.ReadFile(server.MapListPath())
 	if err != nil {
 		c.String(http.StatusInternalServerError, "获取地图记录文件失败")
 		return
@@ -22,22 +23,23 @@ func Clear(c *gin.Context) {
 
 	fileList := strings.Split(string(fileBytes), "\n")
 	errFileList := []string{}
+	defer logic.InvalidateCampaignCache(server, "")
 	for _, file := range fileList {
 		if len(file) == 0 {
 			continue
 		}
-		if err := os.Remove(filepath.Join(consts.AddonsBasePath, file)); err != nil {
+		if err := os.Remove(filepath.Join(server.AddonsPath(), file)); err != nil {
 			errFileList = append(errFileList, file)
 		}
 	}
 
 	if len(errFileList) > 0 {
-		os.WriteFile(consts.MapListFilePath, []byte(strings.Join(errFileList, "\n")+"\n"), 0666)
+		os.WriteFile(server.MapListPath(), []byte(strings.Join(errFileList, "\n")+"\n"), 0666)
 		c.String(http.StatusInternalServerError, "以下文件删除失败："+strings.Join(errFileList, ","))
 		return
 	}
 
-	if err := os.WriteFile(consts.MapListFilePath, []byte{}, 0666); err != nil {
+	if err := os.WriteFile(server.MapListPath(), []byte{}, 0666); err != nil {
 		c.String(http.StatusInternalServerError, "清空记录文件失败")
 		return
 	}
@@ -0,0 +1,49 @@
+package controller
+
+import (
+	"io"
+	"l4d2-manager-next/logic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const consoleHeartbeatInterval = 15 * time.Second
+
+// ConsoleStream 以 SSE 格式推送服务器控制台输出，先回放最近的历史再推送实时输出
+// 根路径已挂载静态文件，无法注册 GET 路由，前端需用 fetch 发 POST 请求并流式读取响应
+func ConsoleStream(c *gin.Context) {
+	hub := logic.GetConsoleHub(getServer(c))
+	ch, history := hub.Subscribe()
+	defer hub.Unsubscribe(ch)
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁止 nginx 缓冲
+
+	for _, line := range history {
+		c.SSEvent("line", line)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(consoleHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case line, ok := <-ch:
+			if !ok {
+				return false
+			}
+			c.SSEvent("line", line)
+			return true
+		case <-heartbeat.C:
+			// SSE 注释行，保持连接不被代理断开
+			_, err := io.WriteString(w, ": ping\n\n")
+			return err == nil
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,36 @@
+package controller
+
+import (
+	"l4d2-manager-next/logic"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListCvars 按前缀查询 cvar，commands=true 时包含命令
+func ListCvars(c *gin.Context) {
+	cvars, err := logic.ListCvars(getServer(c), c.PostForm("prefix"), c.PostForm("commands") == "true")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cvars)
+}
+
+func GetCvar(c *gin.Context) {
+	info, err := logic.GetCvar(getServer(c), c.PostForm("name"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
+
+func SetCvar(c *gin.Context) {
+	info, err := logic.SetCvar(getServer(c), c.PostForm("name"), c.PostForm("value"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error(), "cvar": info})
+		return
+	}
+	c.JSON(http.StatusOK, info)
+}
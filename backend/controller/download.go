@@ -3,7 +3,7 @@ package controller
 import (
 	"fmt"
 	"io"
-	"l4d2-manager-next/consts"
+	"l4d2-manager-next/logic"
 	"mime"
 	"net/http"
 	"os"
@@ -28,28 +28,32 @@ const (
 )
 
 type downloadTask struct {
-	url              string          // 下载链接
-	status           DOWNLOAD_STATUS // 状态
-	message          string          // 错误消息
-	progress         float64         // 进度
-	cancel           chan struct{}   // 取消信号通道
-	cancelled        bool            // 标记是否已取消
-	downloadSpeed    float64         // 下载速度 (bytes/second)
-	startTime        time.Time       // 下载开始时间
-	lastUpdate       time.Time       // 上次更新时间
-	downloadedBytes  int64           // 已下载字节数
-	lastSecondBytes  int64           // 上一秒的下载字节数
-	speedUpdateTimer *time.Ticker    // 速度更新定时器
-	mu               sync.RWMutex    // 读写锁，保护并发访问
-	semaphore        chan struct{}   // 并发控制信号量
-	totalSize        int64           // 文件总大小
-	filename         string          // 文件名
+	url              string            // 下载链接
+	server           *logic.GameServer // 目标服务器
+	uploader         string            // 添加任务的用户
+	status           DOWNLOAD_STATUS   // 状态
+	message          string            // 错误消息
+	progress         float64           // 进度
+	cancel           chan struct{}     // 取消信号通道
+	cancelled        bool              // 标记是否已取消
+	downloadSpeed    float64           // 下载速度 (bytes/second)
+	startTime        time.Time         // 下载开始时间
+	lastUpdate       time.Time         // 上次更新时间
+	downloadedBytes  int64             // 已下载字节数
+	lastSecondBytes  int64             // 上一秒的下载字节数
+	speedUpdateTimer *time.Ticker      // 速度更新定时器
+	mu               sync.RWMutex      // 读写锁，保护并发访问
+	semaphore        chan struct{}     // 并发控制信号量
+	totalSize        int64             // 文件总大小
+	filename         string            // 文件名
 }
 
 // 新增下载任务
-func NewDownloadTask(url string, semaphore chan struct{}) *downloadTask {
+func NewDownloadTask(server *logic.GameServer, url, uploader string, semaphore chan struct{}) *downloadTask {
 	res := &downloadTask{
 		url:              url,
+		server:           server,
+		uploader:         uploader,
 		status:           DOWNLOAD_STATUS_PENDING,
 		cancel:           make(chan struct{}),
 		cancelled:        false,
@@ -154,7 +158,7 @@ func (dt *downloadTask) download() {
 	dt.mu.Unlock()
 
 	// 创建本地文件
-	filePath := filepath.Join(consts.AddonsBasePath, "temp", fileName)
+	filePath := filepath.Join(dt.server.AddonsPath(), "temp", fileName)
 	err = os.MkdirAll(filepath.Dir(filePath), 0755)
 	if err != nil {
 		dt.message = fmt.Sprintf("创建目录失败: %v", err)
@@ -267,11 +271,14 @@ func (dt *downloadTask) download() {
 	}
 
 	// 下载完成后处理文件
-	if err := ProcessFile(filePath); err != nil {
+	saved, err := ProcessFile(dt.server, filePath, dt.uploader)
+	if err != nil {
 		dt.message = fmt.Sprintf("文件处理失败: %v", err)
 		dt.status = DOWNLOAD_STATUS_FAILED
 		return
 	}
+	// 与已有地图冲突时在任务信息中提示
+	dt.message = strings.TrimPrefix(conflictWarning(dt.server, saved), "\n")
 }
 
 // 确定文件名
@@ -399,8 +406,8 @@ func NewDownloader() *downloader {
 	}
 }
 
-func (d *downloader) AddTask(url string) {
-	task := NewDownloadTask(url, d.semaphore)
+func (d *downloader) AddTask(server *logic.GameServer, url, uploader string) {
+	task := NewDownloadTask(server, url, uploader, d.semaphore)
 	d.tasks = append(d.tasks, task)
 }
 
@@ -409,6 +416,7 @@ func (d *downloader) GetTasksInfo() []map[string]any {
 	for _, task := range d.tasks {
 		tasksInfo = append(tasksInfo, map[string]any{
 			"url":            task.url,
+			"serverId":       task.server.ID,
 			"status":         task.GetStatus(),
 			"progress":       task.GetProgress(),
 			"message":        task.GetMessage(),
@@ -429,7 +437,8 @@ func init() {
 }
 
 func AddDownloadTask(c *gin.Context) {
-	if stat, err := disk.Usage(consts.AddonsBasePath); err != nil {
+	server := getServer(c)
+	if stat, err := disk.Usage(server.AddonsPath()); err != nil {
 		c.String(http.StatusInternalServerError, "获取磁盘使用信息失败: %v", err)
 	} else if stat.UsedPercent > 90 {
 		c.String(http.StatusInsufficientStorage, "磁盘空间不足，当前使用率超过90%")
@@ -445,7 +454,7 @@ func AddDownloadTask(c *gin.Context) {
 	// 识别切分多个http连接
 	urls := splitURLString(url)
 	for _, singleURL := range urls {
-		Downloader.AddTask(singleURL)
+		Downloader.AddTask(server, singleURL, actorName(c))
 	}
 	c.String(http.StatusOK, "下载任务已添加")
 }
@@ -514,7 +523,7 @@ func RestartDownloadTask(c *gin.Context) {
 	originalTask.Cancel()
 
 	// 创建新的下载任务
-	newTask := NewDownloadTask(taskURL, Downloader.semaphore)
+	newTask := NewDownloadTask(originalTask.server, taskURL, originalTask.uploader, Downloader.semaphore)
 
 	// 替换原任务
 	Downloader.tasks[index] = newTask
@@ -5,7 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"l4d2-manager-next/consts"
+	"l4d2-manager-next/logic"
+	"log"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -19,10 +20,10 @@ import (
 var chineseDecoder = mahonia.NewDecoder("gbk")
 
 // checkMapExists 检查地图文件是否已存在
-func checkMapExists(filename string) error {
-	_, statErr := os.Stat(consts.MapListFilePath)
+func checkMapExists(server *logic.GameServer, filename string) error {
+	_, statErr := os.Stat(server.MapListPath())
 	if !os.IsNotExist(statErr) {
-		maps, readErr := os.ReadFile(consts.MapListFilePath)
+		maps, readErr := os.ReadFile(server.MapListPath())
 		if readErr != nil {
 			return errors.New("获取地图记录文件失败")
 		}
@@ -53,12 +54,12 @@ func extractFile(f *zip.File, destPath string) error {
 	return err
 }
 
-// recordMap 将地图文件名记录到maplist.txt
-func recordMap(filename string) error {
+// recordMap 将地图文件名记录到maplist.txt，并在地图库中记录上传者
+func recordMap(server *logic.GameServer, filename, uploader string) error {
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	list, openErr := os.OpenFile(consts.MapListFilePath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
+	list, openErr := os.OpenFile(server.MapListPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0666)
 	if openErr != nil {
 		return errors.New("获取地图记录文件句柄失败")
 	}
@@ -67,9 +68,24 @@ func recordMap(filename string) error {
 	if _, err := list.WriteString(filename + "\n"); err != nil {
 		return errors.New("写入地图记录失败")
 	}
+	logic.InvalidateCampaignCache(server, filename)
+	logic.RecordMapUpload(server, filename, uploader)
 	return nil
 }
 
+// conflictWarning 检查新保存的vpk与已有vpk的冲突，返回追加在上传结果后的提示，没有冲突时返回空
+func conflictWarning(server *logic.GameServer, saved []string) string {
+	warnings, err := logic.CheckVpkConflicts(server, saved)
+	if err != nil {
+		log.Printf("检查地图冲突失败: %v", err)
+		return ""
+	}
+	if len(warnings) == 0 {
+		return ""
+	}
+	return "\n警告: " + strings.Join(warnings, "；")
+}
+
 // sanitizeFilename 清理文件名中的空格和特殊符号，替换为下划线
 func sanitizeFilename(filename string) string {
 	// 分离文件名和扩展名
@@ -87,8 +103,8 @@ func sanitizeFilename(filename string) string {
 	return cleanName + ext
 }
 
-// ProcessFile 处理文件（vpk或zip或rar或7z），统一的文件处理入口
-func ProcessFile(filePath string) error {
+// ProcessFile 处理文件（vpk或zip或rar或7z），统一的文件处理入口，uploader 为上传者，返回保存的vpk文件名
+func ProcessFile(server *logic.GameServer, filePath, uploader string) ([]string, error) {
 	fileName := filepath.Base(filePath)
 
 	// 检查文件类型
@@ -98,38 +114,38 @@ func ProcessFile(filePath string) error {
 	sevenZipReg := regexp.MustCompile(`\.7z$`)
 
 	if !vpkReg.MatchString(fileName) && !zipReg.MatchString(fileName) && !rarReg.MatchString(fileName) && !sevenZipReg.MatchString(fileName) {
-		return errors.New("不支持的文件类型，只支持vpk, zip, rar, 7z文件")
+		return nil, errors.New("不支持的文件类型，只支持vpk, zip, rar, 7z文件")
 	}
 
 	// 处理zip文件 - 解压并提取vpk文件
 	if zipReg.MatchString(fileName) {
-		return ProcessZipFile(filePath)
+		return ProcessZipFile(server, filePath, uploader)
 	}
 
 	// 处理rar文件
 	if rarReg.MatchString(fileName) {
-		return ProcessRarFile(filePath)
+		return ProcessRarFile(server, filePath, uploader)
 	}
 
 	// 处理7z文件
 	if sevenZipReg.MatchString(fileName) {
-		return Process7zFile(filePath)
+		return Process7zFile(server, filePath, uploader)
 	}
 
 	// 处理vpk文件 - 直接移动到目标目录
 	if vpkReg.MatchString(fileName) {
-		return ProcessVpkFile(filePath)
+		return ProcessVpkFile(server, filePath, uploader)
 	}
 
-	return nil
+	return nil, nil
 }
 
 // ProcessZipFile 处理zip文件，解压并提取vpk文件
-func ProcessZipFile(zipPath string) error {
+func ProcessZipFile(server *logic.GameServer, zipPath, uploader string) ([]string, error) {
 	// 打开zip文件
 	reader, err := zip.OpenReader(zipPath)
 	if err != nil {
-		return fmt.Errorf("打开zip文件失败: %v", err)
+		return nil, fmt.Errorf("打开zip文件失败: %v", err)
 	}
 	defer reader.Close()
 
@@ -147,45 +163,45 @@ func ProcessZipFile(zipPath string) error {
 			cleanName := sanitizeFilename(filepath.Base(name))
 
 			// 检查文件是否已存在
-			if err := checkMapExists(cleanName); err != nil {
-				return err
+			if err := checkMapExists(server, cleanName); err != nil {
+				return nil, err
 			}
 
 			// 解压文件到目标目录
-			destPath := filepath.Join(consts.AddonsBasePath, cleanName)
+			destPath := filepath.Join(server.AddonsPath(), cleanName)
 			if err := extractFile(f, destPath); err != nil {
-				return fmt.Errorf("解压文件失败: %v", err)
+				return nil, fmt.Errorf("解压文件失败: %v", err)
 			}
 			extractedFiles = append(extractedFiles, cleanName)
 		}
 	}
 
 	if len(extractedFiles) == 0 {
-		return errors.New("zip文件中未找到vpk文件")
+		return nil, errors.New("zip文件中未找到vpk文件")
 	}
 
 	// 记录所有解压的vpk文件
 	for _, fileName := range extractedFiles {
-		if err := recordMap(fileName); err != nil {
-			return fmt.Errorf("记录地图失败: %v", err)
+		if err := recordMap(server, fileName, uploader); err != nil {
+			return nil, fmt.Errorf("记录地图失败: %v", err)
 		}
 	}
 
-	return nil
+	return extractedFiles, nil
 }
 
 // ProcessRarFile 处理rar文件
-func ProcessRarFile(rarPath string) error {
+func ProcessRarFile(server *logic.GameServer, rarPath, uploader string) ([]string, error) {
 	// 打开rar文件
 	file, err := os.Open(rarPath)
 	if err != nil {
-		return fmt.Errorf("打开rar文件失败: %v", err)
+		return nil, fmt.Errorf("打开rar文件失败: %v", err)
 	}
 	defer file.Close()
 
 	rr, err := rardecode.NewReader(file, "")
 	if err != nil {
-		return fmt.Errorf("创建rar读取器失败: %v", err)
+		return nil, fmt.Errorf("创建rar读取器失败: %v", err)
 	}
 
 	vpkReg := regexp.MustCompile(`\.vpk$`)
@@ -197,7 +213,7 @@ func ProcessRarFile(rarPath string) error {
 			break
 		}
 		if err != nil {
-			return fmt.Errorf("读取rar内容失败: %v", err)
+			return nil, fmt.Errorf("读取rar内容失败: %v", err)
 		}
 
 		if header.IsDir {
@@ -209,20 +225,20 @@ func ProcessRarFile(rarPath string) error {
 			cleanName := sanitizeFilename(filepath.Base(header.Name))
 
 			// 检查文件是否已存在
-			if err := checkMapExists(cleanName); err != nil {
-				return err
+			if err := checkMapExists(server, cleanName); err != nil {
+				return nil, err
 			}
 
 			// 解压文件到目标目录
-			destPath := filepath.Join(consts.AddonsBasePath, cleanName)
+			destPath := filepath.Join(server.AddonsPath(), cleanName)
 			outFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 			if err != nil {
-				return fmt.Errorf("创建目标文件失败: %v", err)
+				return nil, fmt.Errorf("创建目标文件失败: %v", err)
 			}
 
 			if _, err := io.Copy(outFile, rr); err != nil {
 				outFile.Close()
-				return fmt.Errorf("写入文件失败: %v", err)
+				return nil, fmt.Errorf("写入文件失败: %v", err)
 			}
 			outFile.Close()
 
@@ -231,24 +247,24 @@ func ProcessRarFile(rarPath string) error {
 	}
 
 	if len(extractedFiles) == 0 {
-		return errors.New("rar文件中未找到vpk文件")
+		return nil, errors.New("rar文件中未找到vpk文件")
 	}
 
 	// 记录所有解压的vpk文件
 	for _, fileName := range extractedFiles {
-		if err := recordMap(fileName); err != nil {
-			return fmt.Errorf("记录地图失败: %v", err)
+		if err := recordMap(server, fileName, uploader); err != nil {
+			return nil, fmt.Errorf("记录地图失败: %v", err)
 		}
 	}
 
-	return nil
+	return extractedFiles, nil
 }
 
 // Process7zFile 处理7z文件
-func Process7zFile(sevenZipPath string) error {
+func Process7zFile(server *logic.GameServer, sevenZipPath, uploader string) ([]string, error) {
 	r, err := sevenzip.OpenReader(sevenZipPath)
 	if err != nil {
-		return fmt.Errorf("打开7z文件失败: %v", err)
+		return nil, fmt.Errorf("打开7z文件失败: %v", err)
 	}
 	defer r.Close()
 
@@ -261,29 +277,29 @@ func Process7zFile(sevenZipPath string) error {
 			cleanName := sanitizeFilename(filepath.Base(f.Name))
 
 			// 检查文件是否已存在
-			if err := checkMapExists(cleanName); err != nil {
-				return err
+			if err := checkMapExists(server, cleanName); err != nil {
+				return nil, err
 			}
 
 			// 解压文件到目标目录
-			destPath := filepath.Join(consts.AddonsBasePath, cleanName)
+			destPath := filepath.Join(server.AddonsPath(), cleanName)
 
 			rc, err := f.Open()
 			if err != nil {
-				return fmt.Errorf("打开7z内部文件失败: %v", err)
+				return nil, fmt.Errorf("打开7z内部文件失败: %v", err)
 			}
 
 			outFile, err := os.OpenFile(destPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
 			if err != nil {
 				rc.Close()
-				return fmt.Errorf("创建目标文件失败: %v", err)
+				return nil, fmt.Errorf("创建目标文件失败: %v", err)
 			}
 
 			_, err = io.Copy(outFile, rc)
 			outFile.Close()
 			rc.Close()
 			if err != nil {
-				return fmt.Errorf("写入文件失败: %v", err)
+				return nil, fmt.Errorf("写入文件失败: %v", err)
 			}
 
 			extractedFiles = append(extractedFiles, cleanName)
@@ -291,48 +307,48 @@ func Process7zFile(sevenZipPath string) error {
 	}
 
 	if len(extractedFiles) == 0 {
-		return errors.New("7z文件中未找到vpk文件")
+		return nil, errors.New("7z文件中未找到vpk文件")
 	}
 
 	// 记录所有解压的vpk文件
 	for _, fileName := range extractedFiles {
-		if err := recordMap(fileName); err != nil {
-			return fmt.Errorf("记录地图失败: %v", err)
+		if err := recordMap(server, fileName, uploader); err != nil {
+			return nil, fmt.Errorf("记录地图失败: %v", err)
 		}
 	}
 
-	return nil
+	return extractedFiles, nil
 }
 
 // ProcessVpkFile 处理vpk文件，直接移动到目标目录
-func ProcessVpkFile(vpkPath string) error {
+func ProcessVpkFile(server *logic.GameServer, vpkPath, uploader string) ([]string, error) {
 	fileName := filepath.Base(vpkPath)
 	// 移除temp_前缀（如果存在）
 	fileName = strings.TrimPrefix(fileName, "temp_")
 	cleanName := sanitizeFilename(fileName)
 
 	// 检查文件是否已存在
-	if err := checkMapExists(cleanName); err != nil {
-		return err
+	if err := checkMapExists(server, cleanName); err != nil {
+		return nil, err
 	}
 
 	// 移动文件到目标目录
-	destPath := filepath.Join(consts.AddonsBasePath, cleanName)
+	destPath := filepath.Join(server.AddonsPath(), cleanName)
 	if err := os.Rename(vpkPath, destPath); err != nil {
 		// 如果重命名失败，尝试复制
 		if err := copyFile(vpkPath, destPath); err != nil {
-			return fmt.Errorf("移动文件失败: %v", err)
+			return nil, fmt.Errorf("移动文件失败: %v", err)
 		}
 	}
 
 	// 记录地图
-	if err := recordMap(cleanName); err != nil {
+	if err := recordMap(server, cleanName, uploader); err != nil {
 		// 如果记录失败，删除已复制的文件
 		os.Remove(destPath)
-		return fmt.Errorf("记录地图失败: %v", err)
+		return nil, fmt.Errorf("记录地图失败: %v", err)
 	}
 
-	return nil
+	return []string{cleanName}, nil
 }
 
 // copyFile 复制文件的工具函数
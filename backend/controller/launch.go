@@ -0,0 +1,31 @@
+package controller
+
+import (
+	"l4d2-manager-next/logic"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetLaunchProfile 服务器的启动配置
+func GetLaunchProfile(c *gin.Context) {
+	server := getServer(c)
+	c.JSON(http.StatusOK, gin.H{
+		"profile":    logic.GetLaunchProfile(server),
+		"supervised": server.Supervised(),
+	})
+}
+
+func UpdateLaunchProfile(c *gin.Context) {
+	var req logic.LaunchProfile
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := logic.UpdateLaunchProfile(getServer(c), req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "保存成功，重启服务器后生效"})
+}
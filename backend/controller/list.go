@@ -2,7 +2,7 @@ package controller
 
 import (
 	"fmt"
-	"l4d2-manager-next/consts"
+	"l4d2-manager-next/logic"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -15,10 +15,11 @@ import (
 var mutex sync.RWMutex
 
 func List(c *gin.Context) {
+	server := getServer(c)
+	// 只在读取地图列表时持有锁，解析 VPK 较慢，不阻塞上传和删除
 	mutex.RLock()
-	defer mutex.RUnlock()
-
-	file, err := os.ReadFile(consts.MapListFilePath)
+	file, err := os.ReadFile(server.MapListPath())
+	mutex.RUnlock()
 	if err != nil {
 		c.String(http.StatusInternalServerError, "读取地图列表失败")
 		return
@@ -27,6 +28,7 @@ func List(c *gin.Context) {
 	// 获取vpk文件的大小
 	mapList := strings.TrimSpace(string(file))
 	maps := strings.Split(mapList, "\n")
+	addons := logic.GetAddonInfos(server, maps)
 	var result strings.Builder
 
 	for _, mapName := range maps {
@@ -35,14 +37,19 @@ func List(c *gin.Context) {
 		}
 
 		// 构建vpk文件路径
-		vpkPath := filepath.Join(consts.AddonsBasePath, mapName)
+		vpkPath := filepath.Join(server.AddonsPath(), mapName)
 
 		// 获取文件大小
 		fileInfo, err := os.Stat(vpkPath)
 		if err != nil {
 			result.WriteString(fmt.Sprintf("%s$$unknown\n", mapName))
+			continue
+		}
+		sizeStr := formatFileSize(fileInfo.Size())
+		// 有 addoninfo.txt 时追加标题和作者: 文件名$$大小$$标题$$作者
+		if addon := addons[mapName]; addon != nil {
+			result.WriteString(fmt.Sprintf("%s$$%s$$%s$$%s\n", mapName, sizeStr, cleanListField(addon.Title), cleanListField(addon.Author)))
 		} else {
-			sizeStr := formatFileSize(fileInfo.Size())
 			result.WriteString(fmt.Sprintf("%s$$%s\n", mapName, sizeStr))
 		}
 	}
@@ -50,6 +57,13 @@ func List(c *gin.Context) {
 	c.String(http.StatusOK, result.String())
 }
 
+// cleanListField 去掉会破坏列表格式的分隔符和换行
+// 单个 $ 与相邻字段的 $$ 也会拼成分隔符，所以全部替换
+func cleanListField(s string) string {
+	s = strings.ReplaceAll(s, "$", "")
+	return strings.Join(strings.Fields(s), " ")
+}
+
 // 格式化文件大小
 func formatFileSize(size int64) string {
 	const (
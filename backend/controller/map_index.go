@@ -0,0 +1,38 @@
+package controller
+
+import (
+	"l4d2-manager-next/logic"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetMapLibrary 地图库，包含每个 VPK 的战役、章节、作者和上传信息
+func GetMapLibrary(c *gin.Context) {
+	entries, err := logic.GetMapIndex(getServer(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, entries)
+}
+
+// GetMapConflicts 检查 addons 目录中 VPK 之间的文件冲突和内容重复
+func GetMapConflicts(c *gin.Context) {
+	report, err := logic.AnalyzeVpkConflicts(getServer(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// GetMapFiles 列出 VPK 中的所有文件
+func GetMapFiles(c *gin.Context) {
+	files, err := logic.ListVpkFiles(getServer(c), c.PostForm("file"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, files)
+}
@@ -0,0 +1,47 @@
+package controller
+
+import (
+	"l4d2-manager-next/logic"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchPlayers 搜索玩家历史记录，q 可以是 SteamID、名字或 IP 前缀，按 IP 搜索需要 players.ips 权限
+func SearchPlayers(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.PostForm("offset"))
+	limit, _ := strconv.Atoi(c.PostForm("limit"))
+	if offset < 0 {
+		offset = 0
+	}
+
+	withIPs := logic.HasPermission(c.GetStringSlice("permissions"), logic.PermPlayersIPs)
+	list, total := logic.SearchPlayers(c.PostForm("q"), offset, limit, withIPs)
+	c.JSON(http.StatusOK, gin.H{
+		"total":   total,
+		"players": list,
+	})
+}
+
+func GetPlayerDetail(c *gin.Context) {
+	steamID := c.PostForm("steamid")
+	if steamID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "未提供SteamID"})
+		return
+	}
+
+	player, err := logic.GetPlayer(steamID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+	// IP 记录需要单独的权限
+	if !logic.HasPermission(c.GetStringSlice("permissions"), logic.PermPlayersIPs) {
+		player.IPs = []string{}
+		for i := range player.Sessions {
+			player.Sessions[i].IP = ""
+		}
+	}
+	c.JSON(http.StatusOK, player)
+}
@@ -14,7 +14,7 @@ func GetPluginConfig(c *gin.Context) {
 		return
 	}
 
-	configs, err := logic.GetPluginConfigs(pluginName)
+	configs, err := logic.GetPluginConfigs(getServer(c), pluginName)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -29,19 +29,13 @@ type UpdateConfigRequest struct {
 }
 
 func UpdatePluginConfig(c *gin.Context) {
-	role, _ := c.Get("role")
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
-		return
-	}
-
 	var req UpdateConfigRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request"})
 		return
 	}
 
-	if err := logic.SavePluginConfig(req.ConfigName, req.Updates); err != nil {
+	if err := logic.SavePluginConfig(getServer(c), req.ConfigName, req.Updates); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -10,7 +10,7 @@ import (
 )
 
 func GetPlugins(c *gin.Context) {
-	plugins, err := logic.GetPlugins()
+	plugins, err := logic.GetPlugins(getServer(c))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -19,12 +19,6 @@ func GetPlugins(c *gin.Context) {
 }
 
 func UploadPlugin(c *gin.Context) {
-	role, _ := c.Get("role")
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
-		return
-	}
-
 	form, err := c.MultipartForm()
 	if err != nil {
 		if err = c.Request.ParseMultipartForm(32 << 20); err != nil {
@@ -48,7 +42,7 @@ func UploadPlugin(c *gin.Context) {
 			continue
 		}
 
-		if err := logic.UploadPlugin(file, header.Size, header.Filename); err != nil {
+		if err := logic.UploadPlugin(getServer(c), file, header.Size, header.Filename); err != nil {
 			errs = append(errs, fmt.Sprintf("%s: %v", header.Filename, err))
 		}
 		file.Close()
@@ -62,19 +56,13 @@ func UploadPlugin(c *gin.Context) {
 }
 
 func EnablePlugin(c *gin.Context) {
-	role, _ := c.Get("role")
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
-		return
-	}
-
 	name := c.PostForm("name")
 	if name == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
 		return
 	}
 
-	if err := logic.EnablePlugin(name); err != nil {
+	if err := logic.EnablePlugin(getServer(c), name); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -88,7 +76,7 @@ func DisablePlugin(c *gin.Context) {
 		return
 	}
 
-	if err := logic.DisablePlugin(name); err != nil {
+	if err := logic.DisablePlugin(getServer(c), name); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -96,19 +84,13 @@ func DisablePlugin(c *gin.Context) {
 }
 
 func DeletePlugin(c *gin.Context) {
-	role, _ := c.Get("role")
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "需要管理员权限"})
-		return
-	}
-
 	name := c.PostForm("name")
 	if name == "" {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
 		return
 	}
 
-	if err := logic.DeletePlugin(name); err != nil {
+	if err := logic.DeletePlugin(getServer(c), name); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -0,0 +1,43 @@
+package controller
+
+import (
+	"l4d2-manager-next/logic"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetProcessStatus 由管理器守护的游戏进程状态
+func GetProcessStatus(c *gin.Context) {
+	server := getServer(c)
+	c.JSON(http.StatusOK, gin.H{
+		"supervised": server.Supervised(),
+		"process":    logic.GetSupervisor(server).Status(),
+	})
+}
+
+func StartProcess(c *gin.Context) {
+	server := getServer(c)
+	if !server.Supervised() {
+		c.String(http.StatusBadRequest, "该服务器未开启进程守护")
+		return
+	}
+	if err := logic.GetSupervisor(server).Start(); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "服务器已启动")
+}
+
+func StopProcess(c *gin.Context) {
+	server := getServer(c)
+	if !server.Supervised() {
+		c.String(http.StatusBadRequest, "该服务器未开启进程守护")
+		return
+	}
+	if err := logic.GetSupervisor(server).Stop(); err != nil {
+		c.String(http.StatusInternalServerError, err.Error())
+		return
+	}
+	c.String(http.StatusOK, "服务器已停止")
+}
@@ -1,7 +1,7 @@
 package controller
 
 import (
-	"l4d2-manager-next/consts"
+	"l4d2-manager-next/logic"
 	"net/http"
 	"os"
 	"path/filepath"
@@ -14,15 +14,17 @@ func Remove(c *gin.Context) {
 	mutex.Lock()
 	defer mutex.Unlock()
 
-	mapPath := filepath.Join(consts.AddonsBasePath, c.PostForm("map"))
+	server := getServer(c)
+	mapPath := filepath.Join(server.AddonsPath(), c.PostForm("map"))
 	err := os.Remove(mapPath)
 	if err != nil {
 		c.String(http.StatusBadRequest, "地图不存在")
 		return
 	}
+	logic.InvalidateCampaignCache(server, c.PostForm("map"))
 
 	// 删除maplist.txt中的记录
-	mapListPath := consts.MapListFilePath
+	mapListPath := server.MapListPath()
 	mapListBytes, err := os.ReadFile(mapListPath)
 	if err != nil {
 		c.String(http.StatusBadRequest, "删除时maplist.txt不存在")
@@ -1,61 +1,47 @@
 package controller
 
 import (
-	"fmt"
+	"l4d2-manager-next/logic"
 	"net/http"
-	"os"
-	"os/exec"
-	"runtime"
+	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// Restart 重启服务器
+// mode 为空时立即重启；graceful 倒计时 countdown 秒 (默认 60) 并公告，期间无玩家时提前重启；
+// when_empty 在服务器没有玩家时重启。message 附加在公告后
 func Restart(c *gin.Context) {
-	// 使用RCON重启
-	if os.Getenv("L4D2_RESTART_BY_RCON") == "true" {
-		if err := restartByRcon(); err != nil {
-			c.String(http.StatusInternalServerError, err.Error())
-		}
-		return
-	}
-
-	// 使用命令重启
-	restartCmd := os.Getenv("L4D2_RESTART_CMD")
-	if restartCmd == "" {
-		containerName := os.Getenv("L4D2_CONTAINER_NAME")
-		if containerName == "" {
-			containerName = "l4d2"
-		}
-		restartCmd = "docker restart " + containerName
-	}
+	mode := c.PostForm("mode")
+	countdown, _ := strconv.Atoi(c.PostForm("countdown"))
 
-	var err error
-	if runtime.GOOS == "windows" {
-		err = exec.Command("cmd.exe", "/c", restartCmd).Run()
-	} else {
-		err = exec.Command("sh", "-c", restartCmd).Run()
-	}
+	pending, err := logic.ScheduleRestart(getServer(c), mode, time.Duration(countdown)*time.Second, c.PostForm("message"), actorName(c))
 	if err != nil {
-		fmt.Println("重启失败:", err)
-		c.String(http.StatusInternalServerError, "重启失败")
+		c.String(http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	c.String(http.StatusOK, "重启成功，请等待服务器启动")
-}
-
-func restartByRcon() error {
-	conn, err := getRconConnection()
-	if err != nil {
-		return err
+	switch {
+	case pending == nil:
+		c.String(http.StatusOK, "重启成功，请等待服务器启动")
+	case pending.Deadline != nil:
+		seconds := int(pending.Deadline.Sub(pending.RequestedAt).Seconds())
+		c.String(http.StatusOK, "已开始 %d 秒倒计时，服务器无玩家或倒计时结束后重启", seconds)
+	default:
+		c.String(http.StatusOK, "服务器将在没有玩家时重启")
 	}
+}
 
-	defer conn.Close()
+// GetPendingRestart 返回等待中的重启，没有时返回 null
+func GetPendingRestart(c *gin.Context) {
+	c.JSON(http.StatusOK, logic.GetPendingRestart(getServer(c)))
+}
 
-	_, err = conn.Execute("_restart")
-	if err != nil {
-		return fmt.Errorf("RCON命令执行失败: %v", err)
+func CancelRestart(c *gin.Context) {
+	if err := logic.CancelPendingRestart(getServer(c)); err != nil {
+		c.String(http.StatusBadRequest, err.Error())
+		return
 	}
-
-	return nil
+	c.String(http.StatusOK, "已取消重启")
 }
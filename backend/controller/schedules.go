@@ -0,0 +1,119 @@
+package controller
+
+import (
+	"l4d2-manager-next/logic"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListSchedules(c *gin.Context) {
+	c.JSON(http.StatusOK, logic.ListJobs(getServer(c).ID))
+}
+
+// AddSchedule 添加定时任务，请求体为 JSON，字段见 logic.ScheduledJob
+func AddSchedule(c *gin.Context) {
+	var job logic.ScheduledJob
+	if err := c.ShouldBindJSON(&job); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	job.ServerID = getServer(c).ID
+	if !requireJobPermission(c, job.Type) {
+		return
+	}
+
+	created, err := logic.AddJob(job, actorName(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, created)
+}
+
+func UpdateSchedule(c *gin.Context) {
+	var job logic.ScheduledJob
+	if err := c.ShouldBindJSON(&job); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	job.ServerID = getServer(c).ID
+	if !requireJobPermission(c, job.Type) {
+		return
+	}
+	// 修改类型时原来的任务也需要有权限
+	existing, err := logic.GetJob(job.ServerID, job.ID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !requireJobPermission(c, existing.Type) {
+		return
+	}
+
+	if err := logic.UpdateJob(job); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "保存成功"})
+}
+
+// requireJobPermission 定时任务不能用来执行操作者本身没有权限的操作
+func requireJobPermission(c *gin.Context, jobType string) bool {
+	perm := logic.JobPermission(jobType)
+	if perm != "" && !logic.HasPermission(c.GetStringSlice("permissions"), perm) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "权限不足: " + perm})
+		return false
+	}
+	return true
+}
+
+// jobForRequest 读取请求中 id 对应的任务，并检查任务类型的权限
+func jobForRequest(c *gin.Context) (*logic.ScheduledJob, bool) {
+	job, err := logic.GetJob(getServer(c).ID, c.PostForm("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if !requireJobPermission(c, job.Type) {
+		return nil, false
+	}
+	return job, true
+}
+
+func DeleteSchedule(c *gin.Context) {
+	job, ok := jobForRequest(c)
+	if !ok {
+		return
+	}
+	if err := logic.DeleteJob(job.ServerID, job.ID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+// RunSchedule 立即执行一次任务，返回本次执行记录
+func RunSchedule(c *gin.Context) {
+	job, ok := jobForRequest(c)
+	if !ok {
+		return
+	}
+	run, err := logic.RunJobNow(job.ServerID, job.ID, actorName(c))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !run.Success {
+		c.JSON(http.StatusInternalServerError, run)
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+// ListScheduleRuns 执行记录，id 为空时返回服务器所有任务的记录
+func ListScheduleRuns(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.PostForm("limit"))
+	c.JSON(http.StatusOK, logic.ListJobRuns(getServer(c).ID, c.PostForm("id"), limit))
+}
@@ -0,0 +1,42 @@
+package controller
+
+import (
+	"l4d2-manager-next/logic"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListServerCfgFiles(c *gin.Context) {
+	files, err := logic.ListServerCfgFiles(getServer(c))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, files)
+}
+
+// GetServerCfg 解析后的 server.cfg，runtime=true 时标记运行时被覆盖的 cvar
+func GetServerCfg(c *gin.Context) {
+	cfg, err := logic.GetServerCfg(getServer(c), c.PostForm("file"), c.PostForm("runtime") == "true")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cfg)
+}
+
+func UpdateServerCfg(c *gin.Context) {
+	var req logic.ServerCfgUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	results, err := logic.UpdateServerCfg(getServer(c), req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "保存成功", "applied": results})
+}
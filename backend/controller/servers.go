@@ -0,0 +1,82 @@
+package controller
+
+import (
+	"l4d2-manager-next/logic"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getServer 获取 Server 中间件选中的服务器
+func getServer(c *gin.Context) *logic.GameServer {
+	if server, exists := c.Get("server"); exists {
+		return server.(*logic.GameServer)
+	}
+	server, _ := logic.GetServer(logic.DefaultServerID)
+	return server
+}
+
+// getRconClient 获取当前服务器的 RCON 客户端
+func getRconClient(c *gin.Context) (*logic.RconClient, error) {
+	return getServer(c).Rcon()
+}
+
+func ListServers(c *gin.Context) {
+	servers := logic.ListServers()
+	// 不向前端返回RCON密码
+	for _, server := range servers {
+		server.RconPassword = ""
+	}
+	c.JSON(http.StatusOK, servers)
+}
+
+func AddServer(c *gin.Context) {
+	var req logic.GameServer
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if err := logic.AddServer(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "添加成功"})
+}
+
+func UpdateServer(c *gin.Context) {
+	var req logic.GameServer
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if err := logic.UpdateServer(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "保存成功"})
+}
+
+type DeleteServerRequest struct {
+	ID string `json:"id" binding:"required"`
+}
+
+func DeleteServer(c *gin.Context) {
+	var req DeleteServerRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	// 移除前停止守护的进程，否则进程会脱离管理继续运行
+	if server, err := logic.GetServer(req.ID); err == nil && server.Supervised() {
+		logic.GetSupervisor(server).Stop()
+	}
+
+	if err := logic.DeleteServer(req.ID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
@@ -1,7 +1,7 @@
 package controller
 
 import (
-	"l4d2-manager-next/consts"
+	"l4d2-manager-next/logic"
 	"mime/multipart"
 	"net/http"
 	"os"
@@ -14,7 +14,8 @@ import (
 )
 
 func Upload(c *gin.Context) {
-	if stat, err := disk.Usage(consts.AddonsBasePath); err != nil {
+	server := getServer(c)
+	if stat, err := disk.Usage(server.AddonsPath()); err != nil {
 		c.String(http.StatusInternalServerError, "获取磁盘使用信息失败: %v", err)
 		return
 	} else if stat.UsedPercent > 90 {
@@ -45,33 +46,36 @@ func Upload(c *gin.Context) {
 
 	// 处理zip文件
 	if zipReg.Match([]byte(file.Filename)) {
-		if err := handleZipFile(c, file); err != nil {
+		saved, err := handleZipFile(c, server, file)
+		if err != nil {
 			c.String(http.StatusInternalServerError, err.Error())
 			return
 		}
-		c.String(http.StatusOK, "上传并解压成功！")
+		c.String(http.StatusOK, "上传并解压成功！"+conflictWarning(server, saved))
 		runtime.GC()
 		return
 	}
 
 	// 处理rar文件
 	if rarReg.Match([]byte(file.Filename)) {
-		if err := handleRarFile(c, file); err != nil {
+		saved, err := handleRarFile(c, server, file)
+		if err != nil {
 			c.String(http.StatusInternalServerError, err.Error())
 			return
 		}
-		c.String(http.StatusOK, "上传并解压成功！")
+		c.String(http.StatusOK, "上传并解压成功！"+conflictWarning(server, saved))
 		runtime.GC()
 		return
 	}
 
 	// 处理7z文件
 	if sevenZipReg.Match([]byte(file.Filename)) {
-		if err := handle7zFile(c, file); err != nil {
+		saved, err := handle7zFile(c, server, file)
+		if err != nil {
 			c.String(http.StatusInternalServerError, err.Error())
 			return
 		}
-		c.String(http.StatusOK, "上传并解压成功！")
+		c.String(http.StatusOK, "上传并解压成功！"+conflictWarning(server, saved))
 		runtime.GC()
 		return
 	}
@@ -81,61 +85,62 @@ func Upload(c *gin.Context) {
 	cleanFilename := sanitizeFilename(file.Filename)
 
 	// 检查文件是否已存在
-	if err := checkMapExists(cleanFilename); err != nil {
+	if err := checkMapExists(server, cleanFilename); err != nil {
 		c.String(http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// 保存上传的文件
-	tempPath := filepath.Join(consts.AddonsBasePath, "temp_"+cleanFilename)
+	tempPath := filepath.Join(server.AddonsPath(), "temp_"+cleanFilename)
 	if err := c.SaveUploadedFile(file, tempPath); err != nil {
 		c.String(http.StatusInternalServerError, "文件写入失败")
 		return
 	}
 
 	// 使用共用的文件处理方法
-	if err := ProcessVpkFile(tempPath); err != nil {
+	saved, err := ProcessVpkFile(server, tempPath, actorName(c))
+	if err != nil {
 		os.Remove(tempPath) // 清理临时文件
 		c.String(http.StatusInternalServerError, err.Error())
 		return
 	}
 
-	c.String(http.StatusOK, "上传成功！")
+	c.String(http.StatusOK, "上传成功！"+conflictWarning(server, saved))
 	runtime.GC()
 }
 
-func handleZipFile(c *gin.Context, file *multipart.FileHeader) error {
+func handleZipFile(c *gin.Context, server *logic.GameServer, file *multipart.FileHeader) ([]string, error) {
 	// 保存临时zip文件
-	tempZipPath := filepath.Join(consts.AddonsBasePath, "temp_"+file.Filename)
+	tempZipPath := filepath.Join(server.AddonsPath(), "temp_"+file.Filename)
 	if err := c.SaveUploadedFile(file, tempZipPath); err != nil {
-		return err
+		return nil, err
 	}
 	defer os.Remove(tempZipPath) // 清理临时文件
 
 	// 使用共用的zip文件处理方法
-	return ProcessZipFile(tempZipPath)
+	return ProcessZipFile(server, tempZipPath, actorName(c))
 }
 
-func handleRarFile(c *gin.Context, file *multipart.FileHeader) error {
+func handleRarFile(c *gin.Context, server *logic.GameServer, file *multipart.FileHeader) ([]string, error) {
 	// 保存临时rar文件
-	tempRarPath := filepath.Join(consts.AddonsBasePath, "temp_"+file.Filename)
+	tempRarPath := filepath.Join(server.AddonsPath(), "temp_"+file.Filename)
 	if err := c.SaveUploadedFile(file, tempRarPath); err != nil {
-		return err
+		return nil, err
 	}
 	defer os.Remove(tempRarPath) // 清理临时文件
 
 	// 使用共用的rar文件处理方法
-	return ProcessRarFile(tempRarPath)
+	return ProcessRarFile(server, tempRarPath, actorName(c))
 }
 
-func handle7zFile(c *gin.Context, file *multipart.FileHeader) error {
+func handle7zFile(c *gin.Context, server *logic.GameServer, file *multipart.FileHeader) ([]string, error) {
 	// 保存临时7z文件
-	temp7zPath := filepath.Join(consts.AddonsBasePath, "temp_"+file.Filename)
+	temp7zPath := filepath.Join(server.AddonsPath(), "temp_"+file.Filename)
 	if err := c.SaveUploadedFile(file, temp7zPath); err != nil {
-		return err
+		return nil, err
 	}
 	defer os.Remove(temp7zPath) // 清理临时文件
 
 	// 使用共用的7z文件处理方法
-	return Process7zFile(temp7zPath)
+	return Process7zFile(server, temp7zPath, actorName(c))
 }
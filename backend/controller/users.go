@@ -0,0 +1,138 @@
+package controller
+
+import (
+	"l4d2-manager-next/logic"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListUsers(c *gin.Context) {
+	c.JSON(http.StatusOK, logic.ListUsers())
+}
+
+type AddUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+func AddUser(c *gin.Context) {
+	var req AddUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if !logic.CanManageRole(c.GetString("role"), req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能创建与自己同级或更高级的用户"})
+		return
+	}
+
+	if err := logic.AddUser(req.Username, req.Password, req.Role); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "添加成功"})
+}
+
+type UpdateUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+	Password string `json:"password"` // 留空表示不重置密码
+}
+
+func UpdateUser(c *gin.Context) {
+	var req UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	target, ok := logic.GetUser(req.Username)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	actorRole := c.GetString("role")
+	if !logic.CanManageRole(actorRole, target.Role) || !logic.CanManageRole(actorRole, req.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能修改与自己同级或更高级的用户"})
+		return
+	}
+
+	if err := logic.UpdateUser(req.Username, req.Role, req.Password); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "保存成功"})
+}
+
+type DeleteUserRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+func DeleteUser(c *gin.Context) {
+	var req DeleteUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if req.Username == c.GetString("username") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "不能删除自己"})
+		return
+	}
+
+	target, ok := logic.GetUser(req.Username)
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "用户不存在"})
+		return
+	}
+	if !logic.CanManageRole(c.GetString("role"), target.Role) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "不能删除与自己同级或更高级的用户"})
+		return
+	}
+
+	if err := logic.DeleteUser(req.Username); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "删除成功"})
+}
+
+type ChangePasswordRequest struct {
+	OldPassword string `json:"old_password" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+func ChangePassword(c *gin.Context) {
+	username := c.GetString("username")
+	if username == "" {
+		c.JSON(http.StatusForbidden, gin.H{"error": "临时授权码无法修改密码"})
+		return
+	}
+
+	var req ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的请求参数"})
+		return
+	}
+
+	if err := logic.ChangePassword(username, req.OldPassword, req.NewPassword); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 修改密码后旧会话全部失效，返回新的会话令牌
+	privateKey := c.MustGet("privateKey").([]byte)
+	token, expiresAt, err := logic.IssueSessionToken(privateKey, username)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "生成会话令牌失败"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "密码修改成功",
+		"token":      token,
+		"expires_at": expiresAt,
+	})
+}
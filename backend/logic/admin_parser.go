@@ -2,28 +2,91 @@ package logic
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
-	"l4d2-manager-next/consts"
+	"net"
 	"os"
 	"path/filepath"
 	"regexp"
+	"strconv"
 	"strings"
+
+	"l4d2-manager-next/steamid"
+)
+
+const (
+	AdminSourceSimple = "simple" // admins_simple.ini
+	AdminSourceCfg    = "cfg"    // admins.cfg
 )
 
+// AdminUser SourceMod 管理员
+// Password 只用于写入，读取时不返回，用 HasPassword 表示是否设置了密码
 type AdminUser struct {
-	SteamID string `json:"steamid"`
-	Remark  string `json:"remark"`
+	SteamID     string   `json:"steamid"`
+	Name        string   `json:"name,omitempty"` // admins.cfg 中的节名
+	Remark      string   `json:"remark"`
+	Flags       string   `json:"flags"`
+	Immunity    int      `json:"immunity"`
+	Password    string   `json:"password,omitempty"`
+	HasPassword bool     `json:"has_password"`
+	Groups      []string `json:"groups,omitempty"`
+	Source      string   `json:"source"`
 }
 
-func getAdminsFilePath() string {
-	return filepath.Join(consts.GamePath, "addons", "sourcemod", "configs", "admins_simple.ini")
+// AdminGroup admin_groups.cfg 中的管理组，Overrides 等其他设置在保存时原样保留
+type AdminGroup struct {
+	Name     string `json:"name"`
+	Flags    string `json:"flags"`
+	Immunity int    `json:"immunity"`
+}
+
+var (
+	// admins_simple.ini 每行: "身份" "[免疫等级:]权限[@组名]" ["密码"] // 备注
+	adminLineRegex = regexp.MustCompile(`"([^"]*)"`)
+	adminFlagRegex = regexp.MustCompile(`^[a-tz]*$`)
+	immunityRegex  = regexp.MustCompile(`^(\d+):(.*)$`)
+)
+
+var ErrSourceModMissing = fmt.Errorf("SourceMod 未启用或配置文件不存在")
+
+func getSourceModConfigsPath(server *GameServer) string {
+	return filepath.Join(server.GamePath, "addons", "sourcemod", "configs")
+}
+
+func getAdminsFilePath(server *GameServer) string {
+	return filepath.Join(getSourceModConfigsPath(server), "admins_simple.ini")
+}
+
+func getAdminsCfgPath(server *GameServer) string {
+	return filepath.Join(getSourceModConfigsPath(server), "admins.cfg")
+}
+
+func getAdminGroupsPath(server *GameServer) string {
+	return filepath.Join(getSourceModConfigsPath(server), "admin_groups.cfg")
+}
+
+// ListAdmins 列出 admins_simple.ini 和 admins.cfg 中的所有管理员
+func ListAdmins(server *GameServer) ([]AdminUser, error) {
+	if _, err := os.Stat(getSourceModConfigsPath(server)); os.IsNotExist(err) {
+		return nil, ErrSourceModMissing
+	}
+
+	admins, err := listAllAdmins(server)
+	if err != nil {
+		return nil, err
+	}
+	for i := range admins {
+		admins[i].HasPassword = admins[i].Password != ""
+		admins[i].Password = ""
+	}
+	return admins, nil
 }
 
 // ParseAdminsSimple 解析 admins_simple.ini 文件
-func ParseAdminsSimple() ([]AdminUser, error) {
-	path := getAdminsFilePath()
+func ParseAdminsSimple(server *GameServer) ([]AdminUser, error) {
+	path := getAdminsFilePath(server)
 	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return nil, fmt.Errorf("SourceMod 未启用或配置文件不存在")
+		return nil, ErrSourceModMissing
 	}
 
 	file, err := os.Open(path)
@@ -34,132 +97,442 @@ func ParseAdminsSimple() ([]AdminUser, error) {
 
 	var admins []AdminUser
 	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if admin, ok := parseAdminSimpleLine(scanner.Text()); ok {
+			admins = append(admins, admin)
+		}
+	}
+	return admins, scanner.Err()
+}
 
-	// 正则匹配: "SteamID" "Flags" (可选 "Password") // 注释
-	// 示例: "STEAM_1:1:123456" "99:z" // 我的管理员
-	// 我们只关心 SteamID 和 注释 (Remark)。Flags 保留原样。
-	// 我们假设第一个引号内的字符串是 SteamID。
+func parseAdminSimpleLine(line string) (AdminUser, bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "//") {
+		return AdminUser{}, false
+	}
 
-	// 基础正则匹配引号内的内容
-	re := regexp.MustCompile(`"([^"]+)"`)
+	// 提取注释作为备注
+	remark := ""
+	if idx := strings.Index(line, "//"); idx != -1 {
+		remark = strings.TrimSpace(line[idx+2:])
+		line = line[:idx]
+	}
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "//") {
+	// 至少包含身份和权限，SourceMod 支持 SteamID、IP、名字等多种身份，这里不做限制
+	matches := adminLineRegex.FindAllStringSubmatch(line, -1)
+	if len(matches) < 2 || matches[0][1] == "" {
+		return AdminUser{}, false
+	}
+
+	admin := AdminUser{
+		SteamID: matches[0][1],
+		Remark:  remark,
+		Source:  AdminSourceSimple,
+	}
+	admin.Immunity, admin.Flags, admin.Groups = parseSimpleFlags(matches[1][1])
+	if len(matches) >= 3 {
+		admin.Password = matches[2][1]
+	}
+	return admin, true
+}
+
+// parseSimpleFlags 解析 "99:bc@Moderators" 形式的权限字段
+func parseSimpleFlags(s string) (int, string, []string) {
+	immunity := 0
+	if m := immunityRegex.FindStringSubmatch(s); m != nil {
+		immunity, _ = strconv.Atoi(m[1])
+		s = m[2]
+	}
+	flags, group, found := strings.Cut(s, "@")
+	if found && group != "" {
+		return immunity, flags, []string{group}
+	}
+	return immunity, flags, nil
+}
+
+func formatAdminSimpleLine(admin AdminUser) string {
+	flags := admin.Flags
+	if len(admin.Groups) > 0 {
+		flags += "@" + admin.Groups[0]
+	}
+	if admin.Immunity > 0 {
+		flags = strconv.Itoa(admin.Immunity) + ":" + flags
+	}
+
+	line := fmt.Sprintf("\"%s\" \"%s\"", admin.SteamID, flags)
+	if admin.Password != "" {
+		line += fmt.Sprintf(" \"%s\"", admin.Password)
+	}
+	if admin.Remark != "" {
+		line += " // " + admin.Remark
+	}
+	return line
+}
+
+// parseAdminsCfg 解析 admins.cfg，文件不存在时返回空的 Admins 节
+func parseAdminsCfg(server *GameServer) (*KeyValue, []AdminUser, error) {
+	root, err := readKeyValuesFile(getAdminsCfgPath(server), "Admins")
+	if err != nil {
+		return nil, nil, fmt.Errorf("解析 admins.cfg 失败: %v", err)
+	}
+
+	var admins []AdminUser
+	for _, node := range root.Child("Admins").Children {
+		if !node.IsBlock {
 			continue
 		}
+		immunity, _ := strconv.Atoi(node.Get("immunity"))
+		admin := AdminUser{
+			SteamID:  node.Get("identity"),
+			Name:     node.Key,
+			Flags:    node.Get("flags"),
+			Immunity: immunity,
+			Password: node.Get("password"),
+			Source:   AdminSourceCfg,
+		}
+		for _, g := range node.ChildrenNamed("group") {
+			admin.Groups = append(admin.Groups, g.Value)
+		}
+		admins = append(admins, admin)
+	}
+	return root, admins, nil
+}
 
-		// 提取注释作为备注
-		remark := ""
-		if idx := strings.Index(line, "//"); idx != -1 {
-			remark = strings.TrimSpace(line[idx+2:])
-			line = line[:idx] // 从解析部分移除注释
+func readKeyValuesFile(path, section string) (*KeyValue, error) {
+	root := &KeyValue{IsBlock: true}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if root, err = ParseKeyValues(bytes.NewReader(data)); err != nil {
+			return nil, err
 		}
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
 
-		matches := re.FindAllStringSubmatch(line, -1)
-		if len(matches) >= 2 {
-			// 至少包含 SteamID 和 Flags
-			steamID := matches[0][1]
-			// flags := matches[1][1] // 忽略 Flags
-
-			// 简单的 SteamID 验证
-			// 原逻辑只允许 STEAM_ 或 [U: 开头，这导致 "1" 这种简写 ID 被忽略
-			// 现在放宽限制：只要非空即可，SourceMod 本身支持多种格式（IP、Name、SteamID等）
-			if steamID != "" {
-				admins = append(admins, AdminUser{
-					SteamID: steamID,
-					Remark:  remark,
-				})
-			}
+	if root.Child(section) == nil {
+		root.Children = append(root.Children, &KeyValue{Key: section, IsBlock: true})
+	}
+	return root, nil
+}
+
+func writeKeyValuesFile(path string, root *KeyValue) error {
+	var buf bytes.Buffer
+	if err := WriteKeyValues(&buf, root); err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf.Bytes(), 0644)
+}
+
+func validateAdmin(admin *AdminUser) error {
+	// 能识别的 SteamID 统一为 STEAM_1:Y:Z，IP、名字等其他身份保持原样
+	admin.SteamID = steamid.Normalize(admin.SteamID)
+	if admin.SteamID == "" {
+		return fmt.Errorf("SteamID 不能为空")
+	}
+	if strings.ContainsAny(admin.SteamID+admin.Password+admin.Remark+admin.Name, "\"\n") {
+		return fmt.Errorf("不能包含引号或换行")
+	}
+	if !adminFlagRegex.MatchString(admin.Flags) {
+		return fmt.Errorf("无效的权限标志: %s，只能使用 a-t 和 z", admin.Flags)
+	}
+	if admin.Immunity < 0 || admin.Immunity > 100 {
+		return fmt.Errorf("免疫等级应在 0-100 之间")
+	}
+	if admin.Source == "" {
+		admin.Source = AdminSourceSimple
+	}
+	if admin.Source != AdminSourceSimple && admin.Source != AdminSourceCfg {
+		return fmt.Errorf("未知的管理员来源: %s", admin.Source)
+	}
+	if admin.Source == AdminSourceSimple && len(admin.Groups) > 1 {
+		return fmt.Errorf("admins_simple.ini 只支持一个组，多个组请使用 admins.cfg")
+	}
+	return nil
+}
+
+func findAdmin(server *GameServer, steamID string) (*AdminUser, error) {
+	admins, err := listAllAdmins(server)
+	if err != nil {
+		return nil, err
+	}
+	for i := range admins {
+		if steamid.Equal(admins[i].SteamID, steamID) {
+			return &admins[i], nil
 		}
 	}
+	return nil, nil
+}
 
-	return admins, scanner.Err()
+// listAllAdmins 与 ListAdmins 相同，但保留密码，仅供内部修改时使用
+func listAllAdmins(server *GameServer) ([]AdminUser, error) {
+	admins, err := ParseAdminsSimple(server)
+	if err != nil && err != ErrSourceModMissing {
+		return nil, err
+	}
+	_, cfgAdmins, err := parseAdminsCfg(server)
+	if err != nil {
+		return nil, err
+	}
+	return append(admins, cfgAdmins...), nil
 }
 
-// AddAdmin 追加新管理员到文件
-func AddAdmin(steamID, remark string) error {
-	path := getAdminsFilePath()
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return fmt.Errorf("SourceMod 未启用或配置文件不存在")
+// AddAdmin 添加管理员到 admins_simple.ini 或 admins.cfg
+func AddAdmin(server *GameServer, admin AdminUser) error {
+	if _, err := os.Stat(getSourceModConfigsPath(server)); os.IsNotExist(err) {
+		return ErrSourceModMissing
+	}
+	if err := validateAdmin(&admin); err != nil {
+		return err
 	}
 
-	// 读取现有列表以检查重复
-	admins, err := ParseAdminsSimple()
+	existing, err := findAdmin(server, admin.SteamID)
 	if err != nil {
 		return err
 	}
+	if existing != nil {
+		return fmt.Errorf("该 SteamID 已存在")
+	}
 
-	for _, admin := range admins {
-		if admin.SteamID == steamID {
-			return fmt.Errorf("该 SteamID 已存在")
+	if admin.Source == AdminSourceCfg {
+		root, _, err := parseAdminsCfg(server)
+		if err != nil {
+			return err
 		}
+		section := root.Child("Admins")
+		section.Children = append(section.Children, adminCfgNode(admin))
+		return writeKeyValuesFile(getAdminsCfgPath(server), root)
 	}
 
-	// 追加到文件
-	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+	path := getAdminsFilePath(server)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
 		return err
 	}
 	defer f.Close()
+	_, err = f.WriteString("\n" + formatAdminSimpleLine(admin))
+	return err
+}
 
-	// 格式: "STEAM_..." "99:z" // 备注
-	line := fmt.Sprintf("\n\"%s\" \"99:z\" // %s", steamID, remark)
-	if _, err := f.WriteString(line); err != nil {
-		return err
+func adminCfgNode(admin AdminUser) *KeyValue {
+	name := admin.Name
+	if name == "" {
+		name = admin.Remark
+	}
+	if name == "" {
+		name = admin.SteamID
 	}
 
-	return nil
+	node := &KeyValue{Key: name, IsBlock: true}
+	node.Set("auth", adminAuthMethod(admin.SteamID))
+	node.Set("identity", admin.SteamID)
+	if admin.Password != "" {
+		node.Set("password", admin.Password)
+	}
+	for _, g := range admin.Groups {
+		node.Children = append(node.Children, &KeyValue{Key: "group", Value: g})
+	}
+	if admin.Flags != "" {
+		node.Set("flags", admin.Flags)
+	}
+	if admin.Immunity > 0 {
+		node.Set("immunity", strconv.Itoa(admin.Immunity))
+	}
+	return node
 }
 
-// DeleteAdmin 根据 SteamID 删除管理员 (重写文件)
-func DeleteAdmin(steamID string) error {
-	path := getAdminsFilePath()
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return fmt.Errorf("SourceMod 未启用或配置文件不存在")
+// adminAuthMethod 根据身份推断 admins.cfg 中的认证方式
+func adminAuthMethod(identity string) string {
+	if _, err := steamid.Parse(identity); err == nil {
+		return "steam"
+	}
+	if net.ParseIP(identity) != nil {
+		return "ip"
 	}
+	return "name"
+}
 
-	// 读取所有行
-	input, err := os.ReadFile(path)
+// UpdateAdmin 修改管理员权限，密码留空表示保持不变，管理员所在的文件不变
+func UpdateAdmin(server *GameServer, admin AdminUser) error {
+	existing, err := findAdmin(server, strings.TrimSpace(admin.SteamID))
 	if err != nil {
 		return err
 	}
+	if existing == nil {
+		return fmt.Errorf("未找到该管理员")
+	}
 
-	lines := strings.Split(string(input), "\n")
-	var newLines []string
-	deleted := false
+	admin.Source = existing.Source
+	if admin.Password == "" {
+		admin.Password = existing.Password
+	}
+	if admin.Name == "" {
+		admin.Name = existing.Name
+	}
+	if err := validateAdmin(&admin); err != nil {
+		return err
+	}
 
-	// 正则用于识别包含特定 SteamID 的行
-	re := regexp.MustCompile(`"([^"]+)"`)
+	if admin.Source == AdminSourceCfg {
+		root, _, err := parseAdminsCfg(server)
+		if err != nil {
+			return err
+		}
+		section := root.Child("Admins")
+		for i, node := range section.Children {
+			if node.IsBlock && node.Get("identity") == existing.SteamID {
+				// 重新生成节点，保留原有的认证方式和注释
+				updated := adminCfgNode(admin)
+				if auth := node.Get("auth"); auth != "" {
+					updated.Set("auth", auth)
+				}
+				updated.Comments = node.Comments
+				updated.EndComments = node.EndComments
+				section.Children[i] = updated
+				break
+			}
+		}
+		return writeKeyValuesFile(getAdminsCfgPath(server), root)
+	}
 
-	for _, line := range lines {
-		trimmed := strings.TrimSpace(line)
-
-		// 检查此行是否包含我们要删除的 SteamID
-		// 我们只检查有效行，不检查纯注释行
-		if trimmed != "" && !strings.HasPrefix(trimmed, "//") {
-			// 移除注释部分以便匹配
-			codePart := trimmed
-			if idx := strings.Index(trimmed, "//"); idx != -1 {
-				codePart = trimmed[:idx]
+	return rewriteAdminsSimple(server, existing.SteamID, func(string) string {
+		return formatAdminSimpleLine(admin)
+	})
+}
+
+// DeleteAdmin 根据 SteamID 删除管理员
+func DeleteAdmin(server *GameServer, steamID string) error {
+	existing, err := findAdmin(server, steamID)
+	if err != nil {
+		return err
+	}
+	if existing == nil {
+		return fmt.Errorf("未找到该管理员")
+	}
+
+	if existing.Source == AdminSourceCfg {
+		root, _, err := parseAdminsCfg(server)
+		if err != nil {
+			return err
+		}
+		section := root.Child("Admins")
+		kept := section.Children[:0]
+		for _, node := range section.Children {
+			if node.IsBlock && node.Get("identity") == existing.SteamID {
+				continue
 			}
+			kept = append(kept, node)
+		}
+		section.Children = kept
+		return writeKeyValuesFile(getAdminsCfgPath(server), root)
+	}
 
-			matches := re.FindAllStringSubmatch(codePart, -1)
-			if len(matches) > 0 {
-				currentSteamID := matches[0][1]
-				if currentSteamID == steamID {
-					deleted = true
-					continue // 跳过此行
-				}
+	return rewriteAdminsSimple(server, existing.SteamID, func(string) string { return "" })
+}
+
+// rewriteAdminsSimple 替换 admins_simple.ini 中指定管理员所在的行，其余行和注释保持不变
+// replace 返回空字符串表示删除该行
+func rewriteAdminsSimple(server *GameServer, steamID string, replace func(line string) string) error {
+	path := getAdminsFilePath(server)
+	input, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(input), "\n")
+	newLines := make([]string, 0, len(lines))
+	found := false
+	for _, line := range lines {
+		if admin, ok := parseAdminSimpleLine(line); ok && admin.SteamID == steamID {
+			found = true
+			if replaced := replace(line); replaced != "" {
+				newLines = append(newLines, replaced)
 			}
+			continue
 		}
 		newLines = append(newLines, line)
 	}
-
-	if !deleted {
+	if !found {
 		return fmt.Errorf("未找到该管理员")
 	}
+	return os.WriteFile(path, []byte(strings.Join(newLines, "\n")), 0644)
+}
+
+// ListAdminGroups 列出 admin_groups.cfg 中的管理组
+func ListAdminGroups(server *GameServer) ([]AdminGroup, error) {
+	root, err := readKeyValuesFile(getAdminGroupsPath(server), "Groups")
+	if err != nil {
+		return nil, fmt.Errorf("解析 admin_groups.cfg 失败: %v", err)
+	}
+
+	groups := make([]AdminGroup, 0)
+	for _, node := range root.Child("Groups").Children {
+		if !node.IsBlock {
+			continue
+		}
+		immunity, _ := strconv.Atoi(node.Get("immunity"))
+		groups = append(groups, AdminGroup{
+			Name:     node.Key,
+			Flags:    node.Get("flags"),
+			Immunity: immunity,
+		})
+	}
+	return groups, nil
+}
 
-	output := strings.Join(newLines, "\n")
-	return os.WriteFile(path, []byte(output), 0644)
+// SaveAdminGroup 新增或修改管理组
+func SaveAdminGroup(server *GameServer, group AdminGroup) error {
+	group.Name = strings.TrimSpace(group.Name)
+	if group.Name == "" || strings.ContainsAny(group.Name, "\"\n@") {
+		return fmt.Errorf("无效的组名")
+	}
+	if !adminFlagRegex.MatchString(group.Flags) {
+		return fmt.Errorf("无效的权限标志: %s，只能使用 a-t 和 z", group.Flags)
+	}
+	if group.Immunity < 0 || group.Immunity > 100 {
+		return fmt.Errorf("免疫等级应在 0-100 之间")
+	}
+	if _, err := os.Stat(getSourceModConfigsPath(server)); os.IsNotExist(err) {
+		return ErrSourceModMissing
+	}
+
+	path := getAdminGroupsPath(server)
+	root, err := readKeyValuesFile(path, "Groups")
+	if err != nil {
+		return fmt.Errorf("解析 admin_groups.cfg 失败: %v", err)
+	}
+
+	section := root.Child("Groups")
+	node := section.Child(group.Name)
+	if node == nil {
+		node = &KeyValue{Key: group.Name, IsBlock: true}
+		section.Children = append(section.Children, node)
+	}
+	node.Set("flags", group.Flags)
+	node.Set("immunity", strconv.Itoa(group.Immunity))
+	return writeKeyValuesFile(path, root)
+}
+
+func DeleteAdminGroup(server *GameServer, name string) error {
+	path := getAdminGroupsPath(server)
+	root, err := readKeyValuesFile(path, "Groups")
+	if err != nil {
+		return fmt.Errorf("解析 admin_groups.cfg 失败: %v", err)
+	}
+
+	section := root.Child("Groups")
+	if section.Child(name) == nil {
+		return fmt.Errorf("未找到管理组 %s", name)
+	}
+	section.Remove(name)
+	return writeKeyValuesFile(path, root)
+}
+
+// ReloadAdmins 通知 SourceMod 重新加载管理员配置
+func ReloadAdmins(server *GameServer) error {
+	client, err := server.Rcon()
+	if err != nil {
+		return err
+	}
+	_, err = client.Execute("sm_reloadadmins")
+	return err
 }
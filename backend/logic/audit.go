@@ -0,0 +1,225 @@
+package logic
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	AuditLogPath     = "audit.log"
+	AuditMaxSizeEnv  = "L4D2_AUDIT_MAX_SIZE"
+	AuditMaxFilesEnv = "L4D2_AUDIT_MAX_FILES"
+
+	defaultAuditMaxSize  = 10 // MB
+	defaultAuditMaxFiles = 5
+)
+
+// AuditEntry 一条审计记录，每行一个 JSON 追加写入 audit.log
+type AuditEntry struct {
+	Time     time.Time         `json:"time"`
+	Actor    string            `json:"actor"`
+	Role     string            `json:"role"`
+	IP       string            `json:"ip"`
+	Action   string            `json:"action"`
+	ServerID string            `json:"server_id,omitempty"`
+	Target   string            `json:"target,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+	Status   int               `json:"status"`
+	Result   string            `json:"result"`
+	Message  string            `json:"message,omitempty"`
+}
+
+// AuditFilter 查询条件，零值字段表示不过滤
+type AuditFilter struct {
+	Actor  string
+	Action string // 支持 "maps.*" 形式的前缀匹配
+	Since  time.Time
+	Until  time.Time
+	Offset int
+	Limit  int
+}
+
+var (
+	auditMutex sync.Mutex
+	// 查询时持有读锁，轮转时持有写锁，避免查询过程中文件被改名
+	auditRotateMutex sync.RWMutex
+)
+
+// RecordAudit 追加一条审计记录，超过大小上限时先轮转
+func RecordAudit(entry AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	auditMutex.Lock()
+	defer auditMutex.Unlock()
+
+	if info, err := os.Stat(AuditLogPath); err == nil && info.Size()+int64(len(data)) > getAuditMaxSize() {
+		auditRotateMutex.Lock()
+		err := rotateAuditLog()
+		auditRotateMutex.Unlock()
+		if err != nil {
+			return fmt.Errorf("轮转审计日志失败: %v", err)
+		}
+	}
+
+	f, err := os.OpenFile(AuditLogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = f.Write(append(data, '\n'))
+	return err
+}
+
+// rotateAuditLog audit.log -> audit.log.1 -> audit.log.2 ...，超出保留数量的最旧文件被删除
+func rotateAuditLog() error {
+	maxFiles := getAuditMaxFiles()
+	os.Remove(auditFilePath(maxFiles))
+	for i := maxFiles - 1; i >= 0; i-- {
+		if _, err := os.Stat(auditFilePath(i)); err == nil {
+			if err := os.Rename(auditFilePath(i), auditFilePath(i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func auditFilePath(index int) string {
+	if index == 0 {
+		return AuditLogPath
+	}
+	return fmt.Sprintf("%s.%d", AuditLogPath, index)
+}
+
+// QueryAudit 按条件查询审计记录，包括已轮转的文件，从最新的记录开始倒序读取，
+// 取够 offset+limit 条后停止，hasMore 表示之后还有匹配的记录
+// 查询只阻塞日志轮转，不影响普通的写入
+func QueryAudit(filter AuditFilter) ([]AuditEntry, bool, error) {
+	auditRotateMutex.RLock()
+	defer auditRotateMutex.RUnlock()
+
+	matched := make([]AuditEntry, 0)
+	skipped := 0
+	hasMore := false
+	collect := func(entry *AuditEntry) bool {
+		if !filter.match(entry) {
+			return true
+		}
+		if skipped < filter.Offset {
+			skipped++
+			return true
+		}
+		if filter.Limit > 0 && len(matched) >= filter.Limit {
+			hasMore = true
+			return false
+		}
+		matched = append(matched, *entry)
+		return true
+	}
+	for i := 0; i <= getAuditMaxFiles() && !hasMore; i++ {
+		if err := scanAuditFileReverse(auditFilePath(i), collect); err != nil {
+			return nil, false, err
+		}
+	}
+	return matched, hasMore, nil
+}
+
+// scanAuditFileReverse 从文件末尾按块向前读取，按行倒序回调，fn 返回 false 时停止
+func scanAuditFileReverse(path string, fn func(entry *AuditEntry) bool) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	emit := func(line []byte) bool {
+		var entry AuditEntry
+		// 跳过空行和损坏的行，例如写入中途断电留下的半行或正在写入的行
+		if len(bytes.TrimSpace(line)) == 0 || json.Unmarshal(line, &entry) != nil {
+			return true
+		}
+		return fn(&entry)
+	}
+
+	const blockSize = 64 << 10
+	buf := make([]byte, blockSize)
+	pos := info.Size()
+	var rest []byte // 块开头不完整的行，与前一块拼接后再处理
+	for pos > 0 {
+		n := int64(blockSize)
+		if pos < n {
+			n = pos
+		}
+		pos -= n
+		if _, err := f.ReadAt(buf[:n], pos); err != nil {
+			return err
+		}
+		chunk := append(append([]byte{}, buf[:n]...), rest...)
+		lines := bytes.Split(chunk, []byte{'\n'})
+		rest = lines[0]
+		for i := len(lines) - 1; i >= 1; i-- {
+			if !emit(lines[i]) {
+				return nil
+			}
+		}
+	}
+	emit(rest)
+	return nil
+}
+
+func (f *AuditFilter) match(entry *AuditEntry) bool {
+	if f.Actor != "" && entry.Actor != f.Actor {
+		return false
+	}
+	if f.Action != "" {
+		if strings.HasSuffix(f.Action, ".*") {
+			if !strings.HasPrefix(entry.Action, strings.TrimSuffix(f.Action, "*")) {
+				return false
+			}
+		} else if entry.Action != f.Action {
+			return false
+		}
+	}
+	if !f.Since.IsZero() && entry.Time.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && entry.Time.After(f.Until) {
+		return false
+	}
+	return true
+}
+
+func getAuditMaxSize() int64 {
+	size, err := strconv.Atoi(os.Getenv(AuditMaxSizeEnv))
+	if err != nil || size <= 0 {
+		size = defaultAuditMaxSize
+	}
+	return int64(size) << 20
+}
+
+func getAuditMaxFiles() int {
+	count, err := strconv.Atoi(os.Getenv(AuditMaxFilesEnv))
+	if err != nil || count <= 0 {
+		return defaultAuditMaxFiles
+	}
+	return count
+}
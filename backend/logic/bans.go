@@ -0,0 +1,459 @@
+package logic
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"l4d2-manager-next/steamid"
+)
+
+const (
+	BansFilePath = "bans.json"
+
+	BanTypeSteamID = "steamid"
+	BanTypeIP      = "ip"
+
+	// MaxBanMinutes 限时封禁的最长时长 (10 年)，更长请使用永久封禁
+	MaxBanMinutes = 10 * 365 * 24 * 60
+)
+
+// BanRecord 管理器签发的封禁附加信息，srcds 本身只保存 ID 和时长
+type BanRecord struct {
+	ServerID  string     `json:"server_id"`
+	Type      string     `json:"type"`
+	Target    string     `json:"target"`
+	Name      string     `json:"name,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	Minutes   float64    `json:"minutes"`
+	IssuedBy  string     `json:"issued_by"`
+	IssuedAt  time.Time  `json:"issued_at"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// BanEntry 当前生效的封禁，合并了封禁文件、服务器运行时列表和管理器记录
+type BanEntry struct {
+	Type      string     `json:"type"`
+	Target    string     `json:"target"`
+	Permanent bool       `json:"permanent"`
+	Minutes   float64    `json:"minutes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Name      string     `json:"name,omitempty"`
+	Reason    string     `json:"reason,omitempty"`
+	IssuedBy  string     `json:"issued_by,omitempty"`
+	IssuedAt  *time.Time `json:"issued_at,omitempty"`
+	InFile    bool       `json:"in_file"`    // 已写入 banned_user.cfg / banned_ip.cfg
+	InRuntime bool       `json:"in_runtime"` // 出现在 listid / listip 中
+}
+
+// BanRequest 封禁参数，Target 为 SteamID、在线玩家的 UserID 或 IP，Minutes 为 0 表示永久
+type BanRequest struct {
+	Type     string
+	Target   string
+	Name     string
+	Minutes  float64
+	Reason   string
+	Kick     bool
+	IssuedBy string
+}
+
+var (
+	banRecords      []BanRecord
+	banRecordsMutex sync.Mutex
+
+	banFileRegex = regexp.MustCompile(`^\s*(banid|addip)\s+[\d.]+\s+(\S+)`)
+	banListRegex = regexp.MustCompile(`^\d+\s+(\S+)\s*:\s*(permanent|([\d.]+)\s*min)`)
+	userIDRegex  = regexp.MustCompile(`^\d+$`)
+)
+
+func init() {
+	LoadBanRecords()
+}
+
+func LoadBanRecords() {
+	banRecordsMutex.Lock()
+	defer banRecordsMutex.Unlock()
+
+	banRecords = nil
+	if data, err := os.ReadFile(BansFilePath); err == nil {
+		json.Unmarshal(data, &banRecords)
+	}
+}
+
+// saveBanRecords 保存时清理已过期的限时封禁
+func saveBanRecords() error {
+	now := time.Now()
+	kept := banRecords[:0]
+	for _, r := range banRecords {
+		if r.ExpiresAt == nil || r.ExpiresAt.After(now) {
+			kept = append(kept, r)
+		}
+	}
+	banRecords = kept
+
+	data, err := json.MarshalIndent(banRecords, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(BansFilePath, data, 0644)
+}
+
+func (s *GameServer) BannedUserPath() string {
+	return filepath.Join(s.GamePath, "cfg", "banned_user.cfg")
+}
+
+func (s *GameServer) BannedIPPath() string {
+	return filepath.Join(s.GamePath, "cfg", "banned_ip.cfg")
+}
+
+// ListBans 列出服务器当前的封禁
+// 永久封禁来自封禁文件，限时封禁只存在于服务器内存中，需通过 RCON 的 listid/listip 获取
+func ListBans(s *GameServer) ([]BanEntry, error) {
+	entries := make(map[string]*BanEntry)
+	get := func(banType, target string) *BanEntry {
+		target = normalizeBanTarget(banType, target)
+		key := banType + "|" + target
+		if e, ok := entries[key]; ok {
+			return e
+		}
+		e := &BanEntry{Type: banType, Target: target}
+		entries[key] = e
+		return e
+	}
+
+	for _, path := range []string{s.BannedUserPath(), s.BannedIPPath()} {
+		bans, err := readBanFile(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, ban := range bans {
+			e := get(ban.Type, ban.Target)
+			e.InFile = true
+			e.Permanent = true
+		}
+	}
+
+	if client, err := s.Rcon(); err == nil {
+		for banType, cmd := range map[string]string{BanTypeSteamID: "listid", BanTypeIP: "listip"} {
+			res, err := client.Execute(cmd)
+			if err != nil {
+				continue
+			}
+			for _, line := range strings.Split(res, "\n") {
+				m := banListRegex.FindStringSubmatch(strings.TrimSpace(line))
+				if m == nil {
+					continue
+				}
+				e := get(banType, m[1])
+				e.InRuntime = true
+				if m[2] == "permanent" {
+					e.Permanent = true
+				} else {
+					e.Minutes, _ = strconv.ParseFloat(m[3], 64)
+				}
+			}
+		}
+	}
+
+	banRecordsMutex.Lock()
+	now := time.Now()
+	for _, r := range banRecords {
+		if r.ServerID != s.ID || (r.ExpiresAt != nil && r.ExpiresAt.Before(now)) {
+			continue
+		}
+		e, ok := entries[r.Type+"|"+normalizeBanTarget(r.Type, r.Target)]
+		if !ok {
+			continue
+		}
+		issuedAt := r.IssuedAt
+		e.Name = r.Name
+		e.Reason = r.Reason
+		e.IssuedBy = r.IssuedBy
+		e.IssuedAt = &issuedAt
+		e.ExpiresAt = r.ExpiresAt
+		if r.Minutes > 0 {
+			e.Minutes = r.Minutes
+		}
+	}
+	banRecordsMutex.Unlock()
+
+	list := make([]BanEntry, 0, len(entries))
+	for _, e := range entries {
+		list = append(list, *e)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Type != list[j].Type {
+			return list[i].Type > list[j].Type
+		}
+		return list[i].Target < list[j].Target
+	})
+	return list, nil
+}
+
+type banFileEntry struct {
+	Type   string
+	Target string
+}
+
+func readBanFile(path string) ([]banFileEntry, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var list []banFileEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		m := banFileRegex.FindStringSubmatch(scanner.Text())
+		if m == nil {
+			continue
+		}
+		banType := BanTypeSteamID
+		if m[1] == "addip" {
+			banType = BanTypeIP
+		}
+		list = append(list, banFileEntry{Type: banType, Target: m[2]})
+	}
+	return list, scanner.Err()
+}
+
+// BanPlayer 封禁玩家或 IP
+// SteamID 封禁对不在线的玩家同样有效；服务器无法连接时，永久封禁直接写入封禁文件，下次启动生效
+func BanPlayer(s *GameServer, req BanRequest) error {
+	if req.Minutes < 0 {
+		return fmt.Errorf("封禁时长不能为负数")
+	}
+	if math.IsNaN(req.Minutes) || req.Minutes > MaxBanMinutes {
+		return fmt.Errorf("封禁时长不能超过 %d 分钟，更长请使用永久封禁", MaxBanMinutes)
+	}
+	req.Target = strings.TrimSpace(req.Target)
+	switch req.Type {
+	case BanTypeSteamID:
+		if req.Target == "" {
+			return fmt.Errorf("SteamID或用户ID不能为空")
+		}
+		if id, err := steamid.Parse(req.Target); err == nil {
+			req.Target = id.SteamID2()
+		} else if !userIDRegex.MatchString(req.Target) {
+			return err
+		}
+	case BanTypeIP:
+		if net.ParseIP(req.Target) == nil {
+			return fmt.Errorf("无效的IP地址: %s", req.Target)
+		}
+	default:
+		return fmt.Errorf("未知的封禁类型: %s", req.Type)
+	}
+
+	client, rconErr := s.Rcon()
+	if rconErr == nil && req.Type == BanTypeSteamID && !strings.HasPrefix(req.Target, "STEAM_") {
+		// 按 UserID 封禁时从状态缓存解析出 SteamID，记录才能与封禁列表对应
+		if status, err := GetCachedStatus(s); err == nil {
+			id, _ := strconv.Atoi(req.Target)
+			if u := status.FindPlayerByUserID(id); u != nil && !u.IsBot {
+				req.Target = steamid.Normalize(u.SteamId)
+				if req.Name == "" {
+					req.Name = u.Name
+				}
+			}
+		}
+	}
+	if req.Type == BanTypeSteamID && !strings.HasPrefix(req.Target, "STEAM_") {
+		return fmt.Errorf("用户ID %s 不在线", req.Target)
+	}
+
+	minutes := strconv.FormatFloat(req.Minutes, 'f', -1, 64)
+	if rconErr == nil {
+		var cmds []string
+		if req.Type == BanTypeSteamID {
+			cmd := fmt.Sprintf("banid %s %s", minutes, req.Target)
+			if req.Kick {
+				cmd += " kick"
+			}
+			cmds = append(cmds, cmd)
+			if req.Minutes == 0 {
+				cmds = append(cmds, "writeid")
+			}
+		} else {
+			cmds = append(cmds, fmt.Sprintf("addip %s %s", minutes, req.Target))
+			if req.Minutes == 0 {
+				cmds = append(cmds, "writeip")
+			}
+		}
+		for _, cmd := range cmds {
+			if _, err := client.Execute(cmd); err != nil {
+				return fmt.Errorf("RCON命令执行失败: %v", err)
+			}
+		}
+	} else if req.Minutes == 0 {
+		if err := appendBanFile(s, req.Type, req.Target); err != nil {
+			return fmt.Errorf("写入封禁文件失败: %v", err)
+		}
+	} else {
+		return fmt.Errorf("服务器无法连接，限时封禁需要服务器在线: %v", rconErr)
+	}
+
+	record := BanRecord{
+		ServerID: s.ID,
+		Type:     req.Type,
+		Target:   req.Target,
+		Name:     req.Name,
+		Reason:   req.Reason,
+		Minutes:  req.Minutes,
+		IssuedBy: req.IssuedBy,
+		IssuedAt: time.Now(),
+	}
+	if req.Minutes > 0 {
+		expiresAt := record.IssuedAt.Add(time.Duration(req.Minutes * float64(time.Minute)))
+		record.ExpiresAt = &expiresAt
+	}
+
+	if req.Type == BanTypeSteamID {
+		RecordPlayerBan(req.Target)
+	}
+
+	banRecordsMutex.Lock()
+	defer banRecordsMutex.Unlock()
+	removeBanRecord(s.ID, req.Type, req.Target)
+	banRecords = append(banRecords, record)
+	return saveBanRecords()
+}
+
+// Unban 解除封禁，服务器无法连接时直接修改封禁文件
+// RCON 命令执行失败时仍然修改封禁文件，但返回错误，服务器重启前封禁可能仍然生效
+func Unban(s *GameServer, banType, target string) error {
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return fmt.Errorf("解封对象不能为空")
+	}
+
+	var cmds []string
+	switch banType {
+	case BanTypeSteamID:
+		id, err := steamid.Parse(target)
+		if err != nil {
+			return err
+		}
+		target = id.SteamID2()
+		cmds = []string{"removeid " + target, "writeid"}
+	case BanTypeIP:
+		if net.ParseIP(target) == nil {
+			return fmt.Errorf("无效的IP地址: %s", target)
+		}
+		cmds = []string{"removeip " + target, "writeip"}
+	default:
+		return fmt.Errorf("未知的封禁类型: %s", banType)
+	}
+
+	var rconErr error
+	if client, err := s.Rcon(); err == nil {
+		// 只存在于文件中的封禁 removeid 会返回找不到的提示，不算失败，之后统一从文件中删除
+		for _, cmd := range cmds {
+			if _, err := client.Execute(cmd); err != nil {
+				rconErr = err
+				break
+			}
+		}
+	}
+	if err := removeFromBanFile(s, banType, target); err != nil {
+		return fmt.Errorf("修改封禁文件失败: %v", err)
+	}
+
+	banRecordsMutex.Lock()
+	removeBanRecord(s.ID, banType, target)
+	err := saveBanRecords()
+	banRecordsMutex.Unlock()
+	if rconErr != nil {
+		return fmt.Errorf("已从封禁文件中删除，但RCON命令执行失败，重启后生效: %v", rconErr)
+	}
+	return err
+}
+
+func removeBanRecord(serverID, banType, target string) {
+	kept := banRecords[:0]
+	for _, r := range banRecords {
+		if r.ServerID == serverID && r.Type == banType && normalizeBanTarget(r.Type, r.Target) == target {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	banRecords = kept
+}
+
+// normalizeBanTarget 把 SteamID 统一为 STEAM_1:Y:Z，文件和 listid 中的 STEAM_0 或 [U:1:N] 才能与请求匹配
+func normalizeBanTarget(banType, target string) string {
+	if banType == BanTypeSteamID {
+		return steamid.Normalize(target)
+	}
+	return target
+}
+
+func banFilePath(s *GameServer, banType string) string {
+	if banType == BanTypeIP {
+		return s.BannedIPPath()
+	}
+	return s.BannedUserPath()
+}
+
+func appendBanFile(s *GameServer, banType, target string) error {
+	path := banFilePath(s, banType)
+	bans, err := readBanFile(path)
+	if err != nil {
+		return err
+	}
+	for _, ban := range bans {
+		if normalizeBanTarget(banType, ban.Target) == target {
+			return nil
+		}
+	}
+
+	cmd := "banid"
+	if banType == BanTypeIP {
+		cmd = "addip"
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	_, err = fmt.Fprintf(f, "%s 0 %s\n", cmd, target)
+	return err
+}
+
+func removeFromBanFile(s *GameServer, banType, target string) error {
+	path := banFilePath(s, banType)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	lines := strings.Split(string(data), "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if m := banFileRegex.FindStringSubmatch(line); m != nil && normalizeBanTarget(banType, m[2]) == target {
+			continue
+		}
+		kept = append(kept, line)
+	}
+	return os.WriteFile(path, []byte(strings.Join(kept, "\n")), 0644)
+}
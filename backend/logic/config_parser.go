@@ -37,13 +37,25 @@ func ParseSourceModConfig(path string) ([]CvarConfig, error) {
 	}
 	defer file.Close()
 
-	var cvars []CvarConfig
+	var lines []string
 	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	var cvars []CvarConfig
+	scanCvarLines(lines, func(_ int, config CvarConfig) {
+		cvars = append(cvars, config)
+	})
+	return cvars, scanner.Err()
+}
 
+// scanCvarLines 逐行解析 cvar，连同上方注释中的默认值、范围和说明一起回调，index 为 cvar 所在行号
+func scanCvarLines(lines []string, fn func(index int, config CvarConfig)) {
 	var commentBuffer []string
 
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	for index, raw := range lines {
+		line := strings.TrimSpace(raw)
 
 		if line == "" {
 			continue
@@ -92,7 +104,7 @@ func ParseSourceModConfig(path string) ([]CvarConfig, error) {
 			}
 			
 			config.Description = strings.Join(descLines, "\n")
-			cvars = append(cvars, config)
+			fn(index, config)
 			
 			// Reset buffer
 			commentBuffer = []string{}
@@ -103,8 +115,6 @@ func ParseSourceModConfig(path string) ([]CvarConfig, error) {
 			commentBuffer = []string{}
 		}
 	}
-
-	return cvars, scanner.Err()
 }
 
 func UpdateSourceModConfig(path string, updates map[string]string) error {
@@ -0,0 +1,203 @@
+package logic
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ConsoleSourceConsole = "console" // -condebug 写入的 console.log
+	ConsoleSourceRcon    = "rcon"    // 通过管理器执行的 RCON 命令及其返回
+	ConsoleSourceLog     = "log"     // logaddress_add 推送的 UDP 日志
+	ConsoleSourceProcess = "process" // 由管理器守护的进程的标准输出
+
+	consoleHistorySize   = 500
+	consoleBacklogBytes  = 64 << 10
+	consoleMaxReadBytes  = 1 << 20
+	consolePollInterval  = 500 * time.Millisecond
+	consoleSubscriberBuf = 256
+)
+
+// ConsoleLine 控制台输出中的一行
+type ConsoleLine struct {
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+	Text   string    `json:"text"`
+}
+
+// ConsoleHub 汇总一个服务器的控制台输出并分发给订阅者
+// 有订阅者时才跟踪 console.log，最近的输出保存在内存中供新订阅者回放
+type ConsoleHub struct {
+	path string
+
+	mu          sync.Mutex
+	history     []ConsoleLine
+	subscribers map[chan ConsoleLine]struct{}
+	stop        chan struct{}
+}
+
+var (
+	consoleHubs      = make(map[string]*ConsoleHub)
+	consoleHubsMutex sync.Mutex
+)
+
+// ConsoleLogPath srcds 以 -condebug 启动时输出的控制台日志
+func (s *GameServer) ConsoleLogPath() string {
+	return filepath.Join(s.GamePath, "console.log")
+}
+
+// GetConsoleHub 获取服务器的控制台汇总，游戏目录变更后重新创建
+func GetConsoleHub(s *GameServer) *ConsoleHub {
+	consoleHubsMutex.Lock()
+	defer consoleHubsMutex.Unlock()
+
+	hub, ok := consoleHubs[s.ID]
+	if ok && hub.path == s.ConsoleLogPath() {
+		return hub
+	}
+	hub = &ConsoleHub{
+		path:        s.ConsoleLogPath(),
+		subscribers: make(map[chan ConsoleLine]struct{}),
+	}
+	consoleHubs[s.ID] = hub
+	return hub
+}
+
+// Subscribe 订阅控制台输出，返回订阅通道和最近的历史输出
+func (h *ConsoleHub) Subscribe() (chan ConsoleLine, []ConsoleLine) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	ch := make(chan ConsoleLine, consoleSubscriberBuf)
+	h.subscribers[ch] = struct{}{}
+	if h.stop == nil {
+		// 重新开始跟踪时会回放文件末尾，清空旧的历史避免重复
+		h.history = nil
+		h.stop = make(chan struct{})
+		go h.tail(h.stop)
+	}
+
+	history := make([]ConsoleLine, len(h.history))
+	copy(history, h.history)
+	return ch, history
+}
+
+// Unsubscribe 取消订阅，最后一个订阅者离开时停止跟踪 console.log
+func (h *ConsoleHub) Unsubscribe(ch chan ConsoleLine) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := h.subscribers[ch]; !ok {
+		return
+	}
+	delete(h.subscribers, ch)
+	close(ch)
+	if len(h.subscribers) == 0 && h.stop != nil {
+		close(h.stop)
+		h.stop = nil
+	}
+}
+
+// Publish 推送一行输出，多行文本按行拆分
+func (h *ConsoleHub) Publish(source, text string) {
+	now := time.Now()
+	for _, line := range strings.Split(strings.TrimRight(text, "\r\n"), "\n") {
+		h.publishLine(ConsoleLine{Time: now, Source: source, Text: strings.TrimRight(line, "\r")})
+	}
+}
+
+func (h *ConsoleHub) publishLine(line ConsoleLine) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.history = append(h.history, line)
+	if len(h.history) > consoleHistorySize {
+		h.history = h.history[len(h.history)-consoleHistorySize:]
+	}
+	for ch := range h.subscribers {
+		// 客户端消费过慢时丢弃，避免阻塞其他订阅者
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+// tail 轮询 console.log 的新增内容，首次读取时回放文件末尾的一部分
+// 每次轮询重新打开文件，服务器重启后文件被截断或替换也能继续跟踪
+func (h *ConsoleHub) tail(stop chan struct{}) {
+	ticker := time.NewTicker(consolePollInterval)
+	defer ticker.Stop()
+
+	var offset int64 = -1
+	pending := ""
+	skipPartial := false
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		f, err := os.Open(h.path)
+		if err != nil {
+			// 文件还不存在，出现后从头读取
+			offset = 0
+			continue
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			continue
+		}
+
+		size := info.Size()
+		if offset < 0 {
+			offset = size - consoleBacklogBytes
+			skipPartial = offset > 0
+			if offset < 0 {
+				offset = 0
+			}
+		}
+		if size < offset {
+			offset = 0
+			pending = ""
+		}
+		if size == offset {
+			f.Close()
+			continue
+		}
+
+		data, err := io.ReadAll(io.NewSectionReader(f, offset, min(size-offset, consoleMaxReadBytes)))
+		f.Close()
+		if err != nil {
+			continue
+		}
+		offset += int64(len(data))
+
+		text := pending + string(data)
+		lastNewline := strings.LastIndexByte(text, '\n')
+		if lastNewline < 0 {
+			pending = text
+			continue
+		}
+		pending = text[lastNewline+1:]
+		text = text[:lastNewline]
+
+		if skipPartial {
+			// 从文件中间开始读取，第一行可能不完整
+			skipPartial = false
+			if i := strings.IndexByte(text, '\n'); i >= 0 {
+				text = text[i+1:]
+			} else {
+				continue
+			}
+		}
+		h.Publish(ConsoleSourceConsole, text)
+	}
+}
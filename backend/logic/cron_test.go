@@ -0,0 +1,102 @@
+package logic
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseCron(t *testing.T) {
+	valid := []string{
+		"* * * * *",
+		"*/5 * * * *",
+		"0 4 * * 1-5",
+		"30 2 1,15 * *",
+		"5/10 * * * *",
+		"0 0 * * 7",
+		"0 0 ? * 0",
+		"@daily",
+		"@HOURLY",
+		"  0 12 * * *  ",
+	}
+	for _, spec := range valid {
+		if _, err := ParseCron(spec); err != nil {
+			t.Errorf("ParseCron(%q) 失败: %v", spec, err)
+		}
+	}
+
+	invalid := []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"0 0 0 * *",
+		"0 0 32 * *",
+		"0 0 * 13 *",
+		"0 0 * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+		"1-a * * * *",
+		"@reboot",
+	}
+	for _, spec := range invalid {
+		if _, err := ParseCron(spec); err == nil {
+			t.Errorf("ParseCron(%q) 应当失败", spec)
+		}
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from string
+		want string // 空表示一年内没有匹配
+	}{
+		{"步长", "*/15 * * * *", "2026-10-18 10:07:30", "2026-10-18 10:15:00"},
+		{"严格晚于当前时间", "@hourly", "2026-10-18 10:00:00", "2026-10-18 11:00:00"},
+		{"跳到第二天", "0 4 * * *", "2026-10-18 10:00:00", "2026-10-19 04:00:00"},
+		{"跳到下个月", "0 0 1 * *", "2026-10-18 10:00:00", "2026-11-01 00:00:00"},
+		{"跳到下一年", "0 0 1 1 *", "2026-10-18 10:00:00", "2027-01-01 00:00:00"},
+		{"周日写 0", "0 0 * * 0", "2026-10-18 00:00:00", "2026-10-25 00:00:00"},
+		{"周日写 7", "0 0 * * 7", "2026-10-18 00:00:00", "2026-10-25 00:00:00"},
+		{"工作日", "30 9 * * 1-5", "2026-10-17 12:00:00", "2026-10-19 09:30:00"},
+		{"日和周满足其一", "0 12 13 * 1", "2026-11-10 00:00:00", "2026-11-13 12:00:00"},
+		{"日和周满足其一 (周先到)", "0 12 13 * 1", "2026-10-14 00:00:00", "2026-10-19 12:00:00"},
+		{"日为 */2 时同时满足日和周", "0 12 */2 * 5", "2026-10-18 13:00:00", "2026-10-23 12:00:00"},
+		{"周为 */2 时同时满足日和周", "0 12 13 * */2", "2026-10-18 13:00:00", "2026-12-13 12:00:00"},
+		{"日为 */2 周不限制", "0 12 */2 * *", "2026-10-18 13:00:00", "2026-10-19 12:00:00"},
+		{"闰日超出一年", "0 0 29 2 *", "2026-03-01 00:00:00", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := ParseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseCron(%q) 失败: %v", tt.spec, err)
+			}
+			got := c.Next(at(tt.from))
+			if tt.want == "" {
+				if !got.IsZero() {
+					t.Errorf("Next = %v，期望零值", got)
+				}
+				return
+			}
+			if want := at(tt.want); !got.Equal(want) {
+				t.Errorf("Next = %v，期望 %v", got, want)
+			}
+			if !c.Match(got) {
+				t.Errorf("Match(%v) = false", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,304 @@
+package logic
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	CvarTypeBool   = "bool"
+	CvarTypeInt    = "int"
+	CvarTypeFloat  = "float"
+	CvarTypeString = "string"
+
+	// 不通过 cvar 接口返回值或修改的 cvar，修改 rcon_password 会导致管理器断开
+	maskedCvarValue = "******"
+)
+
+var protectedCvars = map[string]bool{
+	"rcon_password": true,
+	"sv_password":   true,
+}
+
+var (
+	// cvarlist 的一行: sv_cheats : 0 : , "nf", "rep" : Allow cheats on server
+	cvarListRegex = regexp.MustCompile(`^(\S+)\s*:\s*(.*?)\s*:\s*(.*?)\s*:\s*(.*)$`)
+	cvarFlagRegex = regexp.MustCompile(`"([^"]+)"`)
+	// 查询单个 cvar 的返回: "sv_cheats" = "0" ( def. "0" ) min. 0.000000 max. 1.000000 notify replicated
+	cvarDetailRegex = regexp.MustCompile(`^"([^"]+)" = "([^"]*)"(?:\s*\(\s*def\.\s*"([^"]*)"\s*\))?(.*)$`)
+	cvarMinRegex    = regexp.MustCompile(`min\.\s*(\S+)`)
+	cvarMaxRegex    = regexp.MustCompile(`max\.\s*(\S+)`)
+	cvarPrefixRegex = regexp.MustCompile(`^[a-zA-Z0-9_]*$`)
+)
+
+// CvarInfo 运行中服务器的 cvar
+type CvarInfo struct {
+	Name    string   `json:"name"`
+	Value   string   `json:"value"`
+	Default string   `json:"default,omitempty"`
+	Min     string   `json:"min,omitempty"`
+	Max     string   `json:"max,omitempty"`
+	Type    string   `json:"type,omitempty"`
+	Flags   []string `json:"flags"`
+	Help    string   `json:"help,omitempty"`
+	Command bool     `json:"command"`
+}
+
+// ListCvars 通过 cvarlist 按前缀查询 cvar，includeCommands 为 false 时过滤掉命令
+// cvarlist 不包含默认值和范围，需要时调用 GetCvar
+func ListCvars(s *GameServer, prefix string, includeCommands bool) ([]CvarInfo, error) {
+	prefix = strings.TrimSpace(prefix)
+	if !cvarPrefixRegex.MatchString(prefix) {
+		return nil, fmt.Errorf("前缀只能包含字母、数字和下划线")
+	}
+	client, err := s.Rcon()
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Execute(strings.TrimSpace("cvarlist " + prefix))
+	if err != nil {
+		return nil, err
+	}
+
+	cvars := []CvarInfo{}
+	for _, info := range parseCvarList(res) {
+		if info.Command && !includeCommands {
+			continue
+		}
+		// 旧版本的 cvarlist 会忽略参数，这里再按前缀过滤一次
+		if !strings.HasPrefix(strings.ToLower(info.Name), strings.ToLower(prefix)) {
+			continue
+		}
+		cvars = append(cvars, info)
+	}
+	return cvars, nil
+}
+
+func parseCvarList(res string) []CvarInfo {
+	var cvars []CvarInfo
+	for _, line := range strings.Split(res, "\n") {
+		line = strings.TrimSpace(line)
+		// 表头、分隔线和 "xxx total convars/concommands" 都不满足 4 段格式
+		m := cvarListRegex.FindStringSubmatch(line)
+		if m == nil || strings.HasPrefix(line, "--") {
+			continue
+		}
+		info := CvarInfo{
+			Name:  m[1],
+			Value: m[2],
+			Flags: parseCvarFlags(m[3]),
+			Help:  m[4],
+		}
+		if info.Value == "cmd" {
+			info.Command = true
+			info.Value = ""
+		}
+		maskCvar(&info)
+		cvars = append(cvars, info)
+	}
+	return cvars
+}
+
+func parseCvarFlags(s string) []string {
+	flags := []string{}
+	for _, m := range cvarFlagRegex.FindAllStringSubmatch(s, -1) {
+		flags = append(flags, m[1])
+	}
+	return flags
+}
+
+// GetCvar 查询单个 cvar 的当前值、默认值、范围、标志和说明
+func GetCvar(s *GameServer, name string) (*CvarInfo, error) {
+	if !cvarNameRegex.MatchString(name) {
+		return nil, fmt.Errorf("无效的 cvar 名称: %s", name)
+	}
+	client, err := s.Rcon()
+	if err != nil {
+		return nil, err
+	}
+	info, err := queryCvarInfo(client, name)
+	if err != nil {
+		return nil, err
+	}
+	maskCvar(info)
+	return info, nil
+}
+
+// queryCvarInfo 先通过 cvarlist 确认名称是 cvar 而不是命令，再执行名称查询详细信息
+// 直接执行名称时 quit、kickall 这类命令会被当作查询执行
+func queryCvarInfo(client *RconClient, name string) (*CvarInfo, error) {
+	name, err := resolveCvarName(client, name)
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Execute(name)
+	if err != nil {
+		return nil, err
+	}
+	return parseCvarDetail(name, res)
+}
+
+// resolveCvarName 在 cvarlist 中查找名称完全一致的 cvar，返回服务器上的名称
+func resolveCvarName(client *RconClient, name string) (string, error) {
+	if !cvarNameRegex.MatchString(name) {
+		return "", fmt.Errorf("无效的 cvar 名称: %s", name)
+	}
+	res, err := client.Execute("cvarlist " + name)
+	if err != nil {
+		return "", err
+	}
+	for _, info := range parseCvarList(res) {
+		if !strings.EqualFold(info.Name, name) {
+			continue
+		}
+		if info.Command {
+			return "", fmt.Errorf("%s 是命令，不是 cvar", name)
+		}
+		return info.Name, nil
+	}
+	return "", fmt.Errorf("未知的 cvar: %s", name)
+}
+
+func parseCvarDetail(name, res string) (*CvarInfo, error) {
+	lines := strings.Split(res, "\n")
+	for i, line := range lines {
+		m := cvarDetailRegex.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil || !strings.EqualFold(m[1], name) {
+			continue
+		}
+		info := &CvarInfo{
+			Name:    m[1],
+			Value:   m[2],
+			Default: m[3],
+			Flags:   []string{},
+		}
+		rest := m[4]
+		if mm := cvarMinRegex.FindStringSubmatch(rest); mm != nil {
+			info.Min = trimCvarNumber(mm[1])
+			rest = strings.Replace(rest, mm[0], "", 1)
+		}
+		if mm := cvarMaxRegex.FindStringSubmatch(rest); mm != nil {
+			info.Max = trimCvarNumber(mm[1])
+			rest = strings.Replace(rest, mm[0], "", 1)
+		}
+		info.Flags = append(info.Flags, strings.Fields(rest)...)
+
+		// 说明以 " - " 开头，可能有多行
+		var help []string
+		for _, l := range lines[i+1:] {
+			l = strings.TrimSpace(l)
+			if l == "" {
+				continue
+			}
+			help = append(help, strings.TrimSpace(strings.TrimPrefix(l, "-")))
+		}
+		info.Help = strings.Join(help, "\n")
+		info.Type = inferCvarType(info)
+		return info, nil
+	}
+	return nil, fmt.Errorf("未知的 cvar: %s", name)
+}
+
+// trimCvarNumber 将 1.000000 显示为 1
+func trimCvarNumber(s string) string {
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return s
+}
+
+// inferCvarType 根据默认值和范围推断类型，引擎本身不区分 cvar 类型
+func inferCvarType(info *CvarInfo) string {
+	ref := info.Default
+	if ref == "" {
+		ref = info.Value
+	}
+	if info.Min == "0" && info.Max == "1" && (ref == "0" || ref == "1") {
+		return CvarTypeBool
+	}
+	if _, err := strconv.Atoi(ref); err == nil {
+		return CvarTypeInt
+	}
+	if _, err := strconv.ParseFloat(ref, 64); err == nil {
+		return CvarTypeFloat
+	}
+	if info.Min != "" || info.Max != "" {
+		return CvarTypeFloat
+	}
+	return CvarTypeString
+}
+
+func maskCvar(info *CvarInfo) {
+	if protectedCvars[strings.ToLower(info.Name)] {
+		info.Value = maskedCvarValue
+		if info.Default != "" {
+			info.Default = maskedCvarValue
+		}
+	}
+}
+
+// validateCvarValue 按推断的类型和范围校验新值
+func validateCvarValue(info *CvarInfo, value string) error {
+	if info.Type == CvarTypeString {
+		return nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return fmt.Errorf("%s 需要数值", info.Name)
+	}
+	switch info.Type {
+	case CvarTypeBool:
+		if value != "0" && value != "1" {
+			return fmt.Errorf("%s 只能是 0 或 1", info.Name)
+		}
+	case CvarTypeInt:
+		if _, err := strconv.Atoi(value); err != nil {
+			return fmt.Errorf("%s 需要整数", info.Name)
+		}
+	}
+	if min, err := strconv.ParseFloat(info.Min, 64); err == nil && f < min {
+		return fmt.Errorf("%s 不能小于 %s", info.Name, info.Min)
+	}
+	if max, err := strconv.ParseFloat(info.Max, 64); err == nil && f > max {
+		return fmt.Errorf("%s 不能大于 %s", info.Name, info.Max)
+	}
+	return nil
+}
+
+// SetCvar 校验后修改 cvar，返回修改后的值
+// 带 cheat 标志的 cvar 在 sv_cheats 关闭时不会生效，此时返回错误
+func SetCvar(s *GameServer, name, value string) (*CvarInfo, error) {
+	value = strings.TrimSpace(value)
+	if err := validateCvar(name, value); err != nil {
+		return nil, err
+	}
+	if protectedCvars[strings.ToLower(name)] {
+		return nil, fmt.Errorf("%s 不能通过此接口修改", name)
+	}
+	client, err := s.Rcon()
+	if err != nil {
+		return nil, err
+	}
+	info, err := queryCvarInfo(client, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateCvarValue(info, value); err != nil {
+		return nil, err
+	}
+
+	if _, err := client.Execute(fmt.Sprintf(`%s "%s"`, info.Name, value)); err != nil {
+		return nil, err
+	}
+	updated, err := queryCvarInfo(client, name)
+	if err != nil {
+		return nil, err
+	}
+	if !cvarValueEqual(updated.Value, value) {
+		return updated, fmt.Errorf("%s 修改未生效，当前值为 %s", name, updated.Value)
+	}
+	RequestStatusRefresh(s)
+	return updated, nil
+}
@@ -0,0 +1,171 @@
+package logic
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	EventPlayerConnect    = "player_connect"
+	EventPlayerEnter      = "player_enter"
+	EventPlayerDisconnect = "player_disconnect"
+	EventPlayerSay        = "player_say"
+	EventPlayerTeam       = "player_team"
+	EventPlayerKick       = "player_kick"
+	EventPlayerBan        = "player_ban"
+	EventMapStart         = "map_start"
+
+	// 以下事件由状态轮询比较前后两次 status 得出，不依赖日志推送
+	EventStatusMapChange   = "status_map_change"
+	EventStatusPlayerJoin  = "status_player_join"
+	EventStatusPlayerLeave = "status_player_leave"
+
+	eventSubscriberBuf = 256
+)
+
+// Event 事件总线上传递的事件，具体类型见下方各结构体
+type Event interface {
+	EventType() string
+	EventServer() string
+	EventTime() time.Time
+}
+
+// EventBase 所有事件共有的字段
+type EventBase struct {
+	Type     string    `json:"type"`
+	ServerID string    `json:"server_id"`
+	Time     time.Time `json:"time"`
+}
+
+func (e EventBase) EventType() string    { return e.Type }
+func (e EventBase) EventServer() string  { return e.ServerID }
+func (e EventBase) EventTime() time.Time { return e.Time }
+
+// LogPlayer 日志中的玩家信息，形如 "Name<2><STEAM_1:0:123><Survivor>"
+type LogPlayer struct {
+	Name    string `json:"name"`
+	UserID  int    `json:"userid"`
+	SteamID string `json:"steamid"`
+	Team    string `json:"team"`
+}
+
+// IsBot 机器人的 SteamID 为 BOT
+func (p LogPlayer) IsBot() bool {
+	return p.SteamID == "BOT"
+}
+
+type PlayerConnectEvent struct {
+	EventBase
+	Player  LogPlayer `json:"player"`
+	Address string    `json:"address"`
+}
+
+type PlayerEnterEvent struct {
+	EventBase
+	Player LogPlayer `json:"player"`
+}
+
+type PlayerDisconnectEvent struct {
+	EventBase
+	Player LogPlayer `json:"player"`
+	Reason string    `json:"reason"`
+}
+
+type PlayerSayEvent struct {
+	EventBase
+	Player  LogPlayer `json:"player"`
+	Message string    `json:"message"`
+	TeamSay bool      `json:"team_say"`
+}
+
+type PlayerTeamEvent struct {
+	EventBase
+	Player LogPlayer `json:"player"`
+	Team   string    `json:"team"`
+}
+
+type PlayerKickEvent struct {
+	EventBase
+	Player LogPlayer `json:"player"`
+	By     string    `json:"by"`
+	Reason string    `json:"reason"`
+}
+
+// PlayerBanEvent 封禁事件，按 IP 封禁时 Player 为空，Minutes 为 0 表示永久
+type PlayerBanEvent struct {
+	EventBase
+	Player  LogPlayer `json:"player"`
+	IP      string    `json:"ip,omitempty"`
+	Minutes float64   `json:"minutes"`
+	By      string    `json:"by"`
+}
+
+type MapStartEvent struct {
+	EventBase
+	Map string `json:"map"`
+}
+
+// StatusMapChangeEvent 两次轮询之间地图发生了变化
+type StatusMapChangeEvent struct {
+	EventBase
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// StatusPlayerEvent 两次轮询之间玩家加入或离开，不包括机器人
+type StatusPlayerEvent struct {
+	EventBase
+	Player StatusPlayer `json:"player"`
+}
+
+type eventSubscription struct {
+	ch    chan Event
+	types map[string]bool
+}
+
+var (
+	eventSubscribers      = make(map[*eventSubscription]struct{})
+	eventSubscribersMutex sync.RWMutex
+)
+
+// SubscribeEvents 订阅事件，types 为空时订阅所有类型，返回的函数用于取消订阅
+// 订阅者处理过慢时新事件会被丢弃
+func SubscribeEvents(types ...string) (<-chan Event, func()) {
+	sub := &eventSubscription{
+		ch:    make(chan Event, eventSubscriberBuf),
+		types: make(map[string]bool),
+	}
+	for _, t := range types {
+		sub.types[t] = true
+	}
+
+	eventSubscribersMutex.Lock()
+	eventSubscribers[sub] = struct{}{}
+	eventSubscribersMutex.Unlock()
+
+	var once sync.Once
+	return sub.ch, func() {
+		once.Do(func() {
+			eventSubscribersMutex.Lock()
+			delete(eventSubscribers, sub)
+			close(sub.ch)
+			eventSubscribersMutex.Unlock()
+		})
+	}
+}
+
+// PublishEvent 发布事件给所有订阅了该类型的订阅者
+func PublishEvent(e Event) {
+	eventSubscribersMutex.RLock()
+	defer eventSubscribersMutex.RUnlock()
+
+	for sub := range eventSubscribers {
+		if len(sub.types) > 0 && !sub.types[e.EventType()] {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package logic
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+const (
+	RestartModeNow       = ""           // 立即重启
+	RestartModeGraceful  = "graceful"   // 倒计时公告，提前空服时立即重启，到时间后强制重启
+	RestartModeWhenEmpty = "when_empty" // 等到服务器没有玩家时重启
+
+	DefaultRestartCountdown = 60 * time.Second
+	MaxRestartCountdown     = time.Hour
+
+	emptyCheckInterval = 5 * time.Second
+)
+
+// 倒计时剩余这些秒数时发送公告
+var restartAnnounceAt = []int{1800, 900, 600, 300, 120, 60, 30, 10, 5, 4, 3, 2, 1}
+
+// PendingRestart 等待执行的重启
+type PendingRestart struct {
+	ServerID    string     `json:"server_id"`
+	Mode        string     `json:"mode"`
+	RequestedBy string     `json:"requested_by"`
+	RequestedAt time.Time  `json:"requested_at"`
+	Deadline    *time.Time `json:"deadline,omitempty"` // 只有 graceful 模式有截止时间
+	Message     string     `json:"message,omitempty"`  // 附加在倒计时公告后的说明，例如重启原因
+
+	cancel chan struct{}
+}
+
+var (
+	pendingRestarts      = make(map[string]*PendingRestart)
+	pendingRestartsMutex sync.Mutex
+)
+
+// ScheduleRestart 按 mode 重启服务器，RestartModeNow 同步执行，其余模式在后台等待
+// 每个服务器同时只能有一个等待中的重启
+func ScheduleRestart(s *GameServer, mode string, countdown time.Duration, message, by string) (*PendingRestart, error) {
+	if mode == RestartModeNow {
+		return nil, s.Restart()
+	}
+	if mode != RestartModeGraceful && mode != RestartModeWhenEmpty {
+		return nil, fmt.Errorf("未知的重启模式: %s", mode)
+	}
+	if countdown <= 0 {
+		countdown = DefaultRestartCountdown
+	}
+	if countdown > MaxRestartCountdown {
+		return nil, fmt.Errorf("倒计时不能超过 %d 分钟", int(MaxRestartCountdown.Minutes()))
+	}
+
+	pendingRestartsMutex.Lock()
+	defer pendingRestartsMutex.Unlock()
+	if _, ok := pendingRestarts[s.ID]; ok {
+		return nil, fmt.Errorf("已有等待中的重启，请先取消")
+	}
+
+	p := &PendingRestart{
+		ServerID:    s.ID,
+		Mode:        mode,
+		RequestedBy: by,
+		RequestedAt: time.Now(),
+		Message:     strings.TrimSpace(message),
+		cancel:      make(chan struct{}),
+	}
+	if mode == RestartModeGraceful {
+		deadline := p.RequestedAt.Add(countdown)
+		p.Deadline = &deadline
+	}
+	pendingRestarts[s.ID] = p
+	go p.wait(s)
+
+	copied := *p
+	return &copied, nil
+}
+
+// GetPendingRestart 返回服务器等待中的重启，没有时返回 nil
+func GetPendingRestart(s *GameServer) *PendingRestart {
+	pendingRestartsMutex.Lock()
+	defer pendingRestartsMutex.Unlock()
+
+	if p, ok := pendingRestarts[s.ID]; ok {
+		copied := *p
+		return &copied
+	}
+	return nil
+}
+
+// CancelPendingRestart 取消等待中的重启
+func CancelPendingRestart(s *GameServer) error {
+	pendingRestartsMutex.Lock()
+	p, ok := pendingRestarts[s.ID]
+	if !ok {
+		pendingRestartsMutex.Unlock()
+		return fmt.Errorf("没有等待中的重启")
+	}
+	close(p.cancel)
+	delete(pendingRestarts, s.ID)
+	pendingRestartsMutex.Unlock()
+
+	if p.Mode == RestartModeGraceful {
+		announce(s, "服务器重启已取消")
+	}
+	return nil
+}
+
+func (p *PendingRestart) wait(s *GameServer) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	announced := make(map[int]bool)
+	if p.Deadline != nil {
+		p.announceCountdown(s, int(p.Deadline.Sub(p.RequestedAt).Seconds()), announced)
+	}
+	lastCheck := time.Time{}
+	for {
+		select {
+		case <-p.cancel:
+			return
+		case now := <-ticker.C:
+			if p.Deadline != nil {
+				remaining := int(p.Deadline.Sub(now).Round(time.Second).Seconds())
+				if remaining <= 0 {
+					p.fire(s, "倒计时结束")
+					return
+				}
+				p.announceCountdown(s, remaining, announced)
+			}
+
+			if now.Sub(lastCheck) < emptyCheckInterval {
+				continue
+			}
+			lastCheck = now
+			if isServerEmpty(s) {
+				p.fire(s, "服务器已无玩家")
+				return
+			}
+		}
+	}
+}
+
+// announceCountdown 剩余时间第一次低于某个公告点时发送公告，开始时先公告一次总时长
+func (p *PendingRestart) announceCountdown(s *GameServer, remaining int, announced map[int]bool) {
+	point := -1
+	for _, at := range restartAnnounceAt {
+		if remaining <= at {
+			point = at
+		}
+	}
+	if len(announced) == 0 {
+		point = remaining
+	}
+	if point < 0 || announced[point] {
+		return
+	}
+	announced[point] = true
+	for _, at := range restartAnnounceAt {
+		if at >= remaining {
+			announced[at] = true
+		}
+	}
+
+	text := fmt.Sprintf("服务器将在 %s 后重启", formatCountdown(remaining))
+	if p.Message != "" {
+		text += ": " + p.Message
+	}
+	announce(s, text)
+}
+
+func formatCountdown(seconds int) string {
+	if seconds >= 60 && seconds%60 == 0 {
+		return fmt.Sprintf("%d 分钟", seconds/60)
+	}
+	if seconds >= 60 {
+		return fmt.Sprintf("%d 分 %d 秒", seconds/60, seconds%60)
+	}
+	return fmt.Sprintf("%d 秒", seconds)
+}
+
+// isServerEmpty 根据缓存的状态判断是否没有真人玩家，状态未知时视为不为空
+func isServerEmpty(s *GameServer) bool {
+	status, err := GetCachedStatus(s)
+	if err != nil || status.Stale {
+		return false
+	}
+	return status.Humans == 0
+}
+
+func (p *PendingRestart) fire(s *GameServer, reason string) {
+	pendingRestartsMutex.Lock()
+	select {
+	case <-p.cancel:
+		// 在等待锁的过程中被取消
+		pendingRestartsMutex.Unlock()
+		return
+	default:
+	}
+	delete(pendingRestarts, s.ID)
+	pendingRestartsMutex.Unlock()
+
+	err := s.Restart()
+	result, message := "success", ""
+	if err != nil {
+		result, message = "failed", err.Error()
+		log.Printf("服务器 %s 重启失败: %v", s.ID, err)
+	}
+	RecordAudit(AuditEntry{
+		Actor:    p.RequestedBy,
+		Action:   "server.restart." + p.Mode,
+		ServerID: s.ID,
+		Params:   map[string]string{"reason": reason},
+		Result:   result,
+		Message:  message,
+	})
+}
+
+// announce 通过 sm_say 发送公告，没有安装 SourceMod 时使用 say
+func announce(s *GameServer, text string) {
+	client, err := s.Rcon()
+	if err != nil {
+		return
+	}
+	text = sanitizeAnnounce(text)
+	res, err := client.Execute(`sm_say "` + text + `"`)
+	if err != nil || strings.Contains(res, "Unknown command") {
+		client.Execute(`say "` + text + `"`)
+	}
+}
+
+// sanitizeAnnounce 公告会放在引号中作为 RCON 命令的参数，替换掉能结束参数或命令的引号、分号和控制字符
+func sanitizeAnnounce(text string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '"':
+			return '\''
+		case r == ';':
+			return '；'
+		case unicode.IsControl(r):
+			return ' '
+		}
+		return r
+	}, text)
+}
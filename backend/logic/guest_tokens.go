@@ -0,0 +1,188 @@
+package logic
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	GuestTokensFilePath = "guest_tokens.json"
+
+	SelfServiceIssuer = "self-service"
+
+	// 过期超过该时间的授权码记录会被清理
+	guestTokenRetention = 7 * 24 * time.Hour
+)
+
+// GuestToken 已签发的临时授权码记录，授权码本身不保存
+type GuestToken struct {
+	ID          string     `json:"id"`
+	Label       string     `json:"label"`
+	Issuer      string     `json:"issuer"`
+	IssuerIP    string     `json:"issuer_ip"`
+	Permissions []string   `json:"permissions,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty"`
+	RevokedBy   string     `json:"revoked_by,omitempty"`
+}
+
+var (
+	guestTokens      map[string]*GuestToken
+	guestTokensMutex sync.RWMutex
+)
+
+func init() {
+	LoadGuestTokens()
+}
+
+func LoadGuestTokens() {
+	guestTokensMutex.Lock()
+	defer guestTokensMutex.Unlock()
+
+	guestTokens = make(map[string]*GuestToken)
+	data, err := os.ReadFile(GuestTokensFilePath)
+	if err != nil {
+		return
+	}
+	var list []*GuestToken
+	if err := json.Unmarshal(data, &list); err != nil {
+		return
+	}
+	for _, t := range list {
+		guestTokens[t.ID] = t
+	}
+}
+
+func saveGuestTokens() error {
+	// 顺便清理早已过期的记录，避免文件无限增长
+	for id, t := range guestTokens {
+		if time.Since(t.ExpiresAt) > guestTokenRetention {
+			delete(guestTokens, id)
+		}
+	}
+
+	data, err := json.MarshalIndent(sortedGuestTokens(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(GuestTokensFilePath, data, 0600)
+}
+
+func sortedGuestTokens() []*GuestToken {
+	list := make([]*GuestToken, 0, len(guestTokens))
+	for _, t := range guestTokens {
+		list = append(list, t)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.After(list[j].CreatedAt) })
+	return list
+}
+
+// newRandomID 生成 16 位十六进制的随机 ID
+func newRandomID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// IssueGuestToken 签发临时授权码并登记，permissions 为空时使用默认访客权限
+func IssueGuestToken(privateKey []byte, label, issuer, issuerIP string, permissions []string, ttl time.Duration) (string, *GuestToken, error) {
+	id, err := newRandomID()
+	if err != nil {
+		return "", nil, err
+	}
+
+	now := time.Now()
+	record := &GuestToken{
+		ID:          id,
+		Label:       label,
+		Issuer:      issuer,
+		IssuerIP:    issuerIP,
+		Permissions: permissions,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+	claims := GuestClaims{
+		Label:       label,
+		Permissions: permissions,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Issuer:    issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(record.ExpiresAt),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(privateKey)
+	if err != nil {
+		return "", nil, err
+	}
+
+	guestTokensMutex.Lock()
+	defer guestTokensMutex.Unlock()
+	guestTokens[id] = record
+	if err := saveGuestTokens(); err != nil {
+		delete(guestTokens, id)
+		return "", nil, err
+	}
+	copied := *record
+	return token, &copied, nil
+}
+
+// ValidateGuestToken 校验授权码已登记且未被撤销
+// 没有 ID 的旧版授权码无法撤销，一律视为无效
+func ValidateGuestToken(claims *GuestClaims) error {
+	guestTokensMutex.RLock()
+	defer guestTokensMutex.RUnlock()
+
+	t, ok := guestTokens[claims.ID]
+	if claims.ID == "" || !ok {
+		return fmt.Errorf("授权码不存在")
+	}
+	if t.RevokedAt != nil {
+		return fmt.Errorf("授权码已被撤销")
+	}
+	return nil
+}
+
+// ListGuestTokens 返回所有授权码记录，按签发时间倒序
+func ListGuestTokens() []GuestToken {
+	guestTokensMutex.RLock()
+	defer guestTokensMutex.RUnlock()
+
+	list := make([]GuestToken, 0, len(guestTokens))
+	for _, t := range sortedGuestTokens() {
+		list = append(list, *t)
+	}
+	return list
+}
+
+// RevokeGuestToken 撤销授权码，by 为执行撤销的用户
+func RevokeGuestToken(id, by string) error {
+	guestTokensMutex.Lock()
+	defer guestTokensMutex.Unlock()
+
+	t, ok := guestTokens[id]
+	if !ok {
+		return fmt.Errorf("授权码 %s 不存在", id)
+	}
+	if t.RevokedAt != nil {
+		return fmt.Errorf("授权码 %s 已被撤销", id)
+	}
+
+	updated := *t
+	now := time.Now()
+	updated.RevokedAt = &now
+	updated.RevokedBy = by
+	guestTokens[id] = &updated
+	return saveGuestTokens()
+}
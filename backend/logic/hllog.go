@@ -0,0 +1,95 @@
+package logic
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HL 日志格式，srcds 通过 logaddress_add 推送或写入 logs/*.log
+// L 01/02/2024 - 12:34:56: "Name<2><STEAM_1:0:123><Survivor>" say "hello"
+const hlLogTimeLayout = "01/02/2006 - 15:04:05"
+
+const hlPlayerPattern = `"(.*)<(-?\d+)><([^<>]*)><([^<>]*)>"`
+
+var (
+	hlLineRegex       = regexp.MustCompile(`^L (\d{2}/\d{2}/\d{4} - \d{2}:\d{2}:\d{2}): (.*)$`)
+	hlConnectRegex    = regexp.MustCompile(`^` + hlPlayerPattern + ` connected, address "([^"]*)"$`)
+	hlEnterRegex      = regexp.MustCompile(`^` + hlPlayerPattern + ` entered the game$`)
+	hlDisconnectRegex = regexp.MustCompile(`^` + hlPlayerPattern + ` disconnected(?: \(reason "(.*)"\))?$`)
+	hlSayRegex        = regexp.MustCompile(`^` + hlPlayerPattern + ` (say|say_team) "(.*)"$`)
+	hlTeamRegex       = regexp.MustCompile(`^` + hlPlayerPattern + ` joined team "([^"]*)"$`)
+	hlMapStartRegex   = regexp.MustCompile(`^Started map "([^"]+)"`)
+	hlKickRegex       = regexp.MustCompile(`^Kick: ` + hlPlayerPattern + ` was kicked by "(.*?)"(?: \(message "(.*)"\))?$`)
+	hlBanRegex        = regexp.MustCompile(`^Banid: ` + hlPlayerPattern + ` was (?:kicked and )?banned "([^"]*)" by "(.*?)"`)
+	hlAddipRegex      = regexp.MustCompile(`^Addip: "<><><>" was banned by IP "([^"]*)" by "(.*?)" \(IP "([^"]+)"\)`)
+	hlDurationRegex   = regexp.MustCompile(`for ([\d.]+) minutes`)
+)
+
+// ParseLogLine 将一行 HL 日志解析为事件，无法识别的行返回 false
+func ParseLogLine(serverID, line string) (Event, bool) {
+	line = strings.TrimRight(line, "\r\n\x00")
+	m := hlLineRegex.FindStringSubmatch(line)
+	if m == nil {
+		return nil, false
+	}
+
+	t, err := time.ParseInLocation(hlLogTimeLayout, m[1], time.Local)
+	if err != nil {
+		t = time.Now()
+	}
+	base := func(eventType string) EventBase {
+		return EventBase{Type: eventType, ServerID: serverID, Time: t}
+	}
+	body := m[2]
+
+	if m := hlConnectRegex.FindStringSubmatch(body); m != nil {
+		return PlayerConnectEvent{EventBase: base(EventPlayerConnect), Player: parseLogPlayer(m[1:5]), Address: m[5]}, true
+	}
+	if m := hlEnterRegex.FindStringSubmatch(body); m != nil {
+		return PlayerEnterEvent{EventBase: base(EventPlayerEnter), Player: parseLogPlayer(m[1:5])}, true
+	}
+	if m := hlDisconnectRegex.FindStringSubmatch(body); m != nil {
+		return PlayerDisconnectEvent{EventBase: base(EventPlayerDisconnect), Player: parseLogPlayer(m[1:5]), Reason: m[5]}, true
+	}
+	if m := hlSayRegex.FindStringSubmatch(body); m != nil {
+		return PlayerSayEvent{EventBase: base(EventPlayerSay), Player: parseLogPlayer(m[1:5]), Message: m[6], TeamSay: m[5] == "say_team"}, true
+	}
+	if m := hlTeamRegex.FindStringSubmatch(body); m != nil {
+		return PlayerTeamEvent{EventBase: base(EventPlayerTeam), Player: parseLogPlayer(m[1:5]), Team: m[5]}, true
+	}
+	if m := hlMapStartRegex.FindStringSubmatch(body); m != nil {
+		return MapStartEvent{EventBase: base(EventMapStart), Map: m[1]}, true
+	}
+	if m := hlKickRegex.FindStringSubmatch(body); m != nil {
+		return PlayerKickEvent{EventBase: base(EventPlayerKick), Player: parseLogPlayer(m[1:5]), By: m[5], Reason: m[6]}, true
+	}
+	if m := hlBanRegex.FindStringSubmatch(body); m != nil {
+		return PlayerBanEvent{EventBase: base(EventPlayerBan), Player: parseLogPlayer(m[1:5]), Minutes: parseBanMinutes(m[5]), By: m[6]}, true
+	}
+	if m := hlAddipRegex.FindStringSubmatch(body); m != nil {
+		return PlayerBanEvent{EventBase: base(EventPlayerBan), IP: m[3], Minutes: parseBanMinutes(m[1]), By: m[2]}, true
+	}
+	return nil, false
+}
+
+func parseLogPlayer(fields []string) LogPlayer {
+	userID, _ := strconv.Atoi(fields[1])
+	return LogPlayer{
+		Name:    fields[0],
+		UserID:  userID,
+		SteamID: fields[2],
+		Team:    fields[3],
+	}
+}
+
+// parseBanMinutes 解析 "for 5.00 minutes"，"permanently" 返回 0
+func parseBanMinutes(s string) float64 {
+	m := hlDurationRegex.FindStringSubmatch(s)
+	if m == nil {
+		return 0
+	}
+	minutes, _ := strconv.ParseFloat(m[1], 64)
+	return minutes
+}
@@ -0,0 +1,525 @@
+package logic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// KeyValue Valve KeyValues 格式的一个节点
+// 叶子节点只有 Value，块节点的内容在 Children 中，同名键可以重复出现
+type KeyValue struct {
+	Key      string
+	Value    string
+	Children []*KeyValue
+	IsBlock  bool
+	// Condition 平台条件，例如 [$X360]、[!$WIN32]，保存时原样写回
+	Condition string
+	// Comments 节点前的 // 注释，TrailingComment 与值同一行的行尾注释，均不含 //
+	Comments        []string
+	TrailingComment string
+	// EndComments 块中最后一个节点之后、} 之前的注释，根节点为文件末尾的注释
+	EndComments []string
+}
+
+// KeyValuesOptions ParseKeyValuesWith 的选项
+type KeyValuesOptions struct {
+	// Include 读取 #base / #include 引用的文件，为空时忽略这两个指令
+	Include func(name string) (io.ReadCloser, error)
+	// Defines 条件中可用的平台标识 (不含 $)，为空时使用 DefaultKeyValuesDefines
+	Defines map[string]bool
+}
+
+// DefaultKeyValuesDefines 专用服务器所在平台的条件标识，与引擎的判断一致: $WIN32 表示 PC 平台
+var DefaultKeyValuesDefines = map[string]bool{
+	"WIN32":       true,
+	"WINDOWS":     runtime.GOOS == "windows",
+	"LINUX":       runtime.GOOS == "linux",
+	"OSX":         runtime.GOOS == "darwin",
+	"POSIX":       runtime.GOOS != "windows",
+	"X360":        false,
+	"PS3":         false,
+	"GAMECONSOLE": false,
+}
+
+const maxKeyValuesIncludeDepth = 8
+
+// ParseKeyValues 解析 KeyValues 文本，返回的根节点包含文件中所有顶层节点
+// 条件和 #base / #include 指令原样保留在树中，适合读取后修改再写回的文件
+func ParseKeyValues(r io.Reader) (*KeyValue, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	return parseKeyValuesText(string(data), false)
+}
+
+// ParseKeyValuesWith 解析 KeyValues 文本并求值: 去掉条件不成立的节点，展开 #base / #include
+// 解析时允许文件末尾缺少 }，与游戏的容错行为一致，适合只读的任务文件、gameinfo.txt 等
+func ParseKeyValuesWith(r io.Reader, opts KeyValuesOptions) (*KeyValue, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := parseKeyValuesText(string(data), true)
+	if err != nil {
+		return nil, err
+	}
+	if opts.Defines == nil {
+		opts.Defines = DefaultKeyValuesDefines
+	}
+	if err := resolveKeyValues(root, opts, 0); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// ParseKeyValuesFile 读取文件并求值，#base / #include 相对于文件所在目录
+func ParseKeyValuesFile(path string) (*KeyValue, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dir := filepath.Dir(path)
+	return ParseKeyValuesWith(f, KeyValuesOptions{
+		Include: func(name string) (io.ReadCloser, error) {
+			return os.Open(filepath.Join(dir, filepath.FromSlash(name)))
+		},
+	})
+}
+
+func parseKeyValuesText(s string, lenient bool) (*KeyValue, error) {
+	tokens, err := tokenizeKeyValues(s)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &kvParser{tokens: tokens, lenient: lenient}
+	root := &KeyValue{IsBlock: true}
+	if err := p.parseBlock(root, false); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+type kvTokenKind int
+
+const (
+	kvString kvTokenKind = iota
+	kvOpen
+	kvClose
+	kvCondition
+	kvComment
+)
+
+type kvToken struct {
+	kind kvTokenKind
+	text string
+	line int
+}
+
+func tokenizeKeyValues(s string) ([]kvToken, error) {
+	// UTF-8 BOM
+	s = strings.TrimPrefix(s, "\ufeff")
+
+	var tokens []kvToken
+	line := 1
+	for i := 0; i < len(s); {
+		c := s[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		case c == '/' && i+1 < len(s) && s[i+1] == '/':
+			start := i + 2
+			for i < len(s) && s[i] != '\n' {
+				i++
+			}
+			tokens = append(tokens, kvToken{kind: kvComment, text: strings.TrimRight(s[start:i], "\r"), line: line})
+		case c == '{':
+			tokens = append(tokens, kvToken{kind: kvOpen, text: "{", line: line})
+			i++
+		case c == '}':
+			tokens = append(tokens, kvToken{kind: kvClose, text: "}", line: line})
+			i++
+		case c == '[':
+			end := strings.IndexByte(s[i:], ']')
+			if end < 0 || strings.ContainsRune(s[i:i+end], '\n') {
+				return nil, fmt.Errorf("第 %d 行: 条件缺少 ]", line)
+			}
+			tokens = append(tokens, kvToken{kind: kvCondition, text: strings.TrimSpace(s[i+1 : i+end]), line: line})
+			i += end + 1
+		case c == '"':
+			text, n, lines, err := readQuotedKeyValue(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("第 %d 行: %v", line, err)
+			}
+			tokens = append(tokens, kvToken{kind: kvString, text: text, line: line})
+			line += lines
+			i += n
+		default:
+			// 无引号的字符串在空白、括号、引号、条件或注释处结束
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\r\n{}\"[", rune(s[i])) &&
+				!(s[i] == '/' && i+1 < len(s) && s[i+1] == '/') {
+				i++
+			}
+			tokens = append(tokens, kvToken{kind: kvString, text: s[start:i], line: line})
+		}
+	}
+	return tokens, nil
+}
+
+// readQuotedKeyValue 读取以引号开头的字符串，支持 \" \\ \n \t 转义，其他反斜杠原样保留
+// 返回内容、消耗的字节数和跨越的行数
+func readQuotedKeyValue(s string) (string, int, int, error) {
+	var b strings.Builder
+	lines := 0
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; c {
+		case '"':
+			return b.String(), i + 1, lines, nil
+		case '\\':
+			if i+1 < len(s) {
+				switch s[i+1] {
+				case '"', '\\':
+					b.WriteByte(s[i+1])
+					i++
+					continue
+				case 'n':
+					b.WriteByte('\n')
+					i++
+					continue
+				case 't':
+					b.WriteByte('\t')
+					i++
+					continue
+				}
+			}
+			b.WriteByte(c)
+		case '\n':
+			lines++
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", 0, 0, fmt.Errorf("引号未闭合")
+}
+
+type kvParser struct {
+	tokens  []kvToken
+	pos     int
+	lenient bool
+}
+
+func (p *kvParser) peek() *kvToken {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+// comments 读取连续的注释
+func (p *kvParser) comments() []string {
+	var list []string
+	for tok := p.peek(); tok != nil && tok.kind == kvComment; tok = p.peek() {
+		list = append(list, tok.text)
+		p.pos++
+	}
+	return list
+}
+
+// condition 读取可选的条件
+func (p *kvParser) condition() string {
+	if tok := p.peek(); tok != nil && tok.kind == kvCondition {
+		p.pos++
+		return tok.text
+	}
+	return ""
+}
+
+// parseBlock 解析块的内容，注释挂在之后的节点上，块末尾的注释保存在 EndComments
+func (p *kvParser) parseBlock(parent *KeyValue, nested bool) error {
+	var pending []string
+	defer func() {
+		parent.EndComments = append(parent.EndComments, pending...)
+	}()
+	for p.pos < len(p.tokens) {
+		tok := p.tokens[p.pos]
+		switch tok.kind {
+		case kvComment:
+			pending = append(pending, tok.text)
+			p.pos++
+			continue
+		case kvClose:
+			p.pos++
+			if nested {
+				return nil
+			}
+			if p.lenient {
+				continue
+			}
+			return fmt.Errorf("第 %d 行: 多余的 }", tok.line)
+		case kvOpen:
+			return fmt.Errorf("第 %d 行: { 前缺少键名", tok.line)
+		case kvCondition:
+			return fmt.Errorf("第 %d 行: 条件 [%s] 前缺少键名", tok.line, tok.text)
+		}
+		p.pos++
+
+		node := &KeyValue{Key: tok.text, Comments: pending}
+		pending = nil
+		// 条件可以出现在键名和 { 之间，键名和值之间的注释移到节点前
+		node.Comments = append(node.Comments, p.comments()...)
+		node.Condition = p.condition()
+		node.Comments = append(node.Comments, p.comments()...)
+		next := p.peek()
+		switch {
+		case next == nil || next.kind == kvClose:
+			if p.lenient && next == nil {
+				return nil
+			}
+			return fmt.Errorf("第 %d 行: 键 %s 缺少值", tok.line, tok.text)
+		case next.kind == kvOpen:
+			p.pos++
+			node.IsBlock = true
+			if err := p.parseBlock(node, true); err != nil {
+				return err
+			}
+		case next.kind == kvString:
+			node.Value = next.text
+			p.pos++
+			if cond := p.condition(); cond != "" {
+				node.Condition = cond
+			}
+			if c := p.peek(); c != nil && c.kind == kvComment && c.line == next.line {
+				node.TrailingComment = c.text
+				p.pos++
+			}
+		default:
+			return fmt.Errorf("第 %d 行: 键 %s 缺少值", tok.line, tok.text)
+		}
+		parent.Children = append(parent.Children, node)
+	}
+	if nested && !p.lenient {
+		return fmt.Errorf("缺少 }")
+	}
+	return nil
+}
+
+// EvalKeyValuesCondition 求值条件表达式，支持 $NAME、!、&& 和 ||，&& 优先
+func EvalKeyValuesCondition(cond string, defines map[string]bool) bool {
+	for _, or := range strings.Split(cond, "||") {
+		matched := true
+		for _, term := range strings.Split(or, "&&") {
+			term = strings.TrimSpace(term)
+			negate := strings.HasPrefix(term, "!")
+			name := strings.ToUpper(strings.TrimPrefix(strings.TrimPrefix(term, "!"), "$"))
+			if defines[name] == negate {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveKeyValues 去掉条件不成立的节点，并展开顶层的 #include 和 #base
+// #include 的内容追加到顶层，#base 的内容只补充当前文件中没有的键
+func resolveKeyValues(root *KeyValue, opts KeyValuesOptions, depth int) error {
+	filterKeyValueConditions(root, opts.Defines)
+
+	var includes, bases []*KeyValue
+	kept := root.Children[:0]
+	for _, c := range root.Children {
+		switch {
+		case !c.IsBlock && strings.EqualFold(c.Key, "#include"):
+			includes = append(includes, c)
+		case !c.IsBlock && strings.EqualFold(c.Key, "#base"):
+			bases = append(bases, c)
+		default:
+			kept = append(kept, c)
+		}
+	}
+	root.Children = kept
+	if opts.Include == nil || len(includes)+len(bases) == 0 {
+		return nil
+	}
+	if depth >= maxKeyValuesIncludeDepth {
+		return fmt.Errorf("#include / #base 嵌套过深")
+	}
+
+	load := func(name string) (*KeyValue, error) {
+		rc, err := opts.Include(name)
+		if err != nil {
+			return nil, fmt.Errorf("读取 %s 失败: %v", name, err)
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, err
+		}
+		child, err := parseKeyValuesText(string(data), true)
+		if err != nil {
+			return nil, fmt.Errorf("解析 %s 失败: %v", name, err)
+		}
+		if err := resolveKeyValues(child, opts, depth+1); err != nil {
+			return nil, err
+		}
+		return child, nil
+	}
+
+	for _, inc := range includes {
+		child, err := load(inc.Value)
+		if err != nil {
+			return err
+		}
+		root.Children = append(root.Children, child.Children...)
+	}
+	for _, base := range bases {
+		child, err := load(base.Value)
+		if err != nil {
+			return err
+		}
+		mergeBaseKeyValues(root, child)
+	}
+	return nil
+}
+
+func filterKeyValueConditions(kv *KeyValue, defines map[string]bool) {
+	kept := kv.Children[:0]
+	for _, c := range kv.Children {
+		if c.Condition != "" && !EvalKeyValuesCondition(c.Condition, defines) {
+			continue
+		}
+		if c.IsBlock {
+			filterKeyValueConditions(c, defines)
+		}
+		kept = append(kept, c)
+	}
+	kv.Children = kept
+}
+
+// mergeBaseKeyValues 将 base 中 dst 没有的键补充进去，同名的块递归合并
+func mergeBaseKeyValues(dst, base *KeyValue) {
+	for _, b := range base.Children {
+		existing := dst.Child(b.Key)
+		switch {
+		case existing == nil:
+			dst.Children = append(dst.Children, b)
+		case existing.IsBlock && b.IsBlock:
+			mergeBaseKeyValues(existing, b)
+		}
+	}
+}
+
+// Child 按键名查找第一个子节点，不区分大小写
+func (kv *KeyValue) Child(key string) *KeyValue {
+	for _, c := range kv.Children {
+		if strings.EqualFold(c.Key, key) {
+			return c
+		}
+	}
+	return nil
+}
+
+// ChildrenNamed 返回所有同名的子节点
+func (kv *KeyValue) ChildrenNamed(key string) []*KeyValue {
+	var list []*KeyValue
+	for _, c := range kv.Children {
+		if strings.EqualFold(c.Key, key) {
+			list = append(list, c)
+		}
+	}
+	return list
+}
+
+// Get 返回子节点的值，不存在时返回空字符串
+func (kv *KeyValue) Get(key string) string {
+	if c := kv.Child(key); c != nil && !c.IsBlock {
+		return c.Value
+	}
+	return ""
+}
+
+// Set 设置子节点的值，不存在时追加
+func (kv *KeyValue) Set(key, value string) {
+	if c := kv.Child(key); c != nil && !c.IsBlock {
+		c.Value = value
+		return
+	}
+	kv.Children = append(kv.Children, &KeyValue{Key: key, Value: value})
+}
+
+// Remove 删除所有同名子节点
+func (kv *KeyValue) Remove(key string) {
+	kept := kv.Children[:0]
+	for _, c := range kv.Children {
+		if !strings.EqualFold(c.Key, key) {
+			kept = append(kept, c)
+		}
+	}
+	kv.Children = kept
+}
+
+// WriteKeyValues 以 Tab 缩进输出根节点下的所有节点
+func WriteKeyValues(w io.Writer, root *KeyValue) error {
+	bw := bufio.NewWriter(w)
+	for _, c := range root.Children {
+		writeKeyValue(bw, c, 0)
+	}
+	writeKeyValueComments(bw, root.EndComments, "")
+	return bw.Flush()
+}
+
+func writeKeyValue(w *bufio.Writer, kv *KeyValue, depth int) {
+	indent := strings.Repeat("\t", depth)
+	cond := ""
+	if kv.Condition != "" {
+		cond = " [" + kv.Condition + "]"
+	}
+	writeKeyValueComments(w, kv.Comments, indent)
+	if kv.IsBlock {
+		fmt.Fprintf(w, "%s%s%s\n%s{\n", indent, quoteKeyValue(kv.Key), cond, indent)
+		for _, c := range kv.Children {
+			writeKeyValue(w, c, depth+1)
+		}
+		writeKeyValueComments(w, kv.EndComments, indent+"\t")
+		fmt.Fprintf(w, "%s}\n", indent)
+		return
+	}
+	key := quoteKeyValue(kv.Key)
+	// 指令必须不带引号才会被识别
+	if strings.EqualFold(kv.Key, "#base") || strings.EqualFold(kv.Key, "#include") {
+		key = kv.Key
+	}
+	trailing := ""
+	if kv.TrailingComment != "" {
+		trailing = "\t//" + kv.TrailingComment
+	}
+	fmt.Fprintf(w, "%s%s\t\t%s%s%s\n", indent, key, quoteKeyValue(kv.Value), cond, trailing)
+}
+
+func writeKeyValueComments(w *bufio.Writer, comments []string, indent string) {
+	for _, c := range comments {
+		fmt.Fprintf(w, "%s//%s\n", indent, c)
+	}
+}
+
+var kvEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`)
+
+func quoteKeyValue(s string) string {
+	return `"` + kvEscaper.Replace(s) + `"`
+}
@@ -0,0 +1,164 @@
+package logic
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	LaunchEnvFileName = "launch.env"
+	// 原版服务器 tickrate 固定为 30，更高的值需要解锁插件
+	defaultTickrate = 30
+	// 不使用 sv_setmax 时的最大人数
+	defaultMaxPlayers = 8
+)
+
+// start.sh 只为这些 tickrate 准备了 server.cfg 模板，0 表示使用默认值
+var startScriptTickrates = map[int]bool{0: true, 30: true, 60: true, 100: true}
+
+// LaunchProfile 服务器的启动配置，守护模式下由管理器使用，
+// 否则渲染到游戏目录的 launch.env 供 start.sh 读取
+type LaunchProfile struct {
+	Port       int    `json:"port"`
+	Tickrate   int    `json:"tickrate"`
+	MaxPlayers int    `json:"max_players"`
+	SetMax     int    `json:"sv_setmax"` // l4dtoolz 的 sv_setmax，超过 8 人需要
+	Map        string `json:"map"`
+	ExtraArgs  string `json:"extra_args"` // 按空白拆分，不支持引号
+}
+
+// LaunchEnvPath 启动配置文件路径
+func (s *GameServer) LaunchEnvPath() string {
+	return filepath.Join(s.GamePath, LaunchEnvFileName)
+}
+
+// GetLaunchProfile 返回服务器当前的启动配置
+func GetLaunchProfile(s *GameServer) LaunchProfile {
+	if s.Launch == nil {
+		return LaunchProfile{}
+	}
+	return s.Launch.LaunchProfile
+}
+
+// UpdateLaunchProfile 校验并保存启动配置，同时重新生成 launch.env，重启后生效
+func UpdateLaunchProfile(s *GameServer, profile LaunchProfile) error {
+	profile.Map = strings.TrimSpace(profile.Map)
+	profile.ExtraArgs = strings.TrimSpace(profile.ExtraArgs)
+	if err := validateLaunchProfile(&profile); err != nil {
+		return err
+	}
+	if !s.Supervised() && !startScriptTickrates[profile.Tickrate] {
+		return fmt.Errorf("由 start.sh 启动时 tickrate 只能是 30、60 或 100")
+	}
+	if err := checkLaunchPlugins(s, &profile); err != nil {
+		return err
+	}
+
+	managerConfigMutex.Lock()
+	defer managerConfigMutex.Unlock()
+
+	if s.ID == DefaultServerID {
+		managerConfig.DefaultLaunch = &profile
+	} else {
+		found := false
+		for i := range managerConfig.Servers {
+			if managerConfig.Servers[i].ID != s.ID {
+				continue
+			}
+			if managerConfig.Servers[i].Launch == nil {
+				managerConfig.Servers[i].Launch = &LaunchOptions{}
+			}
+			managerConfig.Servers[i].Launch.LaunchProfile = profile
+			found = true
+			break
+		}
+		if !found {
+			return fmt.Errorf("服务器 %s 不存在", s.ID)
+		}
+	}
+	if err := saveManagerConfig(); err != nil {
+		return fmt.Errorf("保存启动配置失败: %v", err)
+	}
+
+	if err := writeLaunchEnv(s.LaunchEnvPath(), profile); err != nil {
+		return fmt.Errorf("生成 %s 失败: %v", LaunchEnvFileName, err)
+	}
+	return nil
+}
+
+// checkLaunchPlugins 高 tickrate 和 sv_setmax 需要对应的解锁插件
+func checkLaunchPlugins(s *GameServer, profile *LaunchProfile) error {
+	if profile.Tickrate > defaultTickrate && !hasAddon(s, "tickrate_enabler", "tick") {
+		return fmt.Errorf("tickrate 超过 %d 需要先启用 tickrate 解锁插件 (tickrate_enabler)", defaultTickrate)
+	}
+	needToolz := profile.SetMax > 0 || profile.MaxPlayers > defaultMaxPlayers
+	if needToolz && !hasAddon(s, "l4dtoolz", "toolz") {
+		return fmt.Errorf("最大人数超过 %d 或设置 sv_setmax 需要先启用 l4dtoolz", defaultMaxPlayers)
+	}
+	if profile.SetMax > 0 && profile.MaxPlayers > profile.SetMax {
+		return fmt.Errorf("最大人数不能超过 sv_setmax")
+	}
+	return nil
+}
+
+// hasAddon 检查 addons 目录中是否有 fileKeyword 开头的文件，或插件库中是否启用了名称包含 pluginKeyword 的插件
+func hasAddon(s *GameServer, fileKeyword, pluginKeyword string) bool {
+	if entries, err := os.ReadDir(s.AddonsPath()); err == nil {
+		for _, e := range entries {
+			if strings.HasPrefix(strings.ToLower(e.Name()), fileKeyword) {
+				return true
+			}
+		}
+	}
+
+	plugins, err := GetPlugins(s)
+	if err != nil {
+		return false
+	}
+	for _, p := range plugins {
+		if p.Status == "enabled" && strings.Contains(strings.ToLower(p.Name), pluginKeyword) {
+			return true
+		}
+	}
+	return false
+}
+
+// writeLaunchEnv 生成可被 shell source 的启动配置，未设置的项不写入，由 start.sh 使用默认值
+func writeLaunchEnv(path string, profile LaunchProfile) error {
+	var b strings.Builder
+	b.WriteString("# 由 l4d2-manager 生成，请通过管理面板修改\n")
+	writeEnv := func(key, value string) {
+		b.WriteString(key + "=" + shellQuote(value) + "\n")
+	}
+	if profile.Port > 0 {
+		writeEnv("L4D2_PORT", strconv.Itoa(profile.Port))
+	}
+	if profile.Tickrate > 0 {
+		writeEnv("L4D2_TICK", strconv.Itoa(profile.Tickrate))
+	}
+	if profile.MaxPlayers > 0 {
+		writeEnv("L4D2_MAXPLAYERS", strconv.Itoa(profile.MaxPlayers))
+	}
+	if profile.SetMax > 0 {
+		writeEnv("L4D2_SETMAX", strconv.Itoa(profile.SetMax))
+	}
+	if profile.Map != "" {
+		writeEnv("L4D2_MAP", profile.Map)
+	}
+	if profile.ExtraArgs != "" {
+		writeEnv("L4D2_SRCDS_ARGS", profile.ExtraArgs)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,235 @@
+package logic
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	LogListenEnv  = "L4D2_LOG_LISTEN"  // UDP 监听地址，例如 :27500，为空时不启用
+	LogAddressEnv = "L4D2_LOG_ADDRESS" // 游戏服务器推送日志的目标地址 IP:Port，为空时不自动注册
+
+	logRegisterInterval = time.Minute
+	logPacketHeader     = "\xff\xff\xff\xff"
+)
+
+// 每个服务器分配一个 sv_logsecret，用于区分日志包来自哪个服务器
+// 已经通过 RCON 设置过 secret 的服务器不再接受没有 secret 的日志包
+var (
+	logSecrets        = make(map[string]string)
+	logSecretsApplied = make(map[string]bool)
+	logSecretsMutex   sync.Mutex
+)
+
+// logServer 服务器及其 RCON 地址解析出的 IP 和端口，用于按来源地址匹配日志包
+// srcds 从游戏端口发送日志，与 RCON 端口相同
+type logServer struct {
+	server *GameServer
+	ips    []net.IP
+	port   int
+}
+
+// 配置变更或定期刷新时重新解析，收到日志包时只读取缓存
+var (
+	logServers      []logServer
+	logServersMutex sync.RWMutex
+	logServersOnce  sync.Mutex // 同一时间只做一次解析
+)
+
+// StartLogListener 启动 UDP 日志接收，并定期通过 RCON 让各服务器推送日志到本机
+func StartLogListener() error {
+	listen := os.Getenv(LogListenEnv)
+	if listen == "" {
+		return nil
+	}
+
+	conn, err := net.ListenPacket("udp", listen)
+	if err != nil {
+		return err
+	}
+	refreshLogServers()
+	go func() {
+		// 容器重启后 IP 可能变化，定期重新解析
+		for range time.Tick(logRegisterInterval) {
+			refreshLogServers()
+		}
+	}()
+	go receiveLogs(conn)
+
+	if address := os.Getenv(LogAddressEnv); address != "" {
+		go registerLogAddress(address)
+	} else {
+		log.Printf("未设置 %s，需要手动在游戏服务器执行 logaddress_add", LogAddressEnv)
+	}
+	return nil
+}
+
+func receiveLogs(conn net.PacketConn) {
+	buf := make([]byte, 4096)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Printf("接收日志失败: %v", err)
+			continue
+		}
+
+		secret, line, ok := parseLogPacket(buf[:n])
+		if !ok {
+			continue
+		}
+		server := matchLogServer(secret, addr)
+		if server == nil {
+			continue
+		}
+
+		GetConsoleHub(server).Publish(ConsoleSourceLog, line)
+		if event, ok := ParseLogLine(server.ID, line); ok {
+			PublishEvent(event)
+		}
+	}
+}
+
+// parseLogPacket 解析日志包: FF FF FF FF 'R' <日志>，设置了 sv_logsecret 时为 FF FF FF FF 'S' <secret> <日志>
+func parseLogPacket(data []byte) (string, string, bool) {
+	if len(data) < 6 || !bytes.HasPrefix(data, []byte(logPacketHeader)) {
+		return "", "", false
+	}
+	payload := strings.TrimRight(string(data[5:]), "\x00\r\n")
+	switch data[4] {
+	case 'R':
+		return "", payload, true
+	case 'S':
+		i := strings.Index(payload, "L ")
+		if i < 0 {
+			return "", "", false
+		}
+		return payload[:i], payload[i:], true
+	}
+	return "", "", false
+}
+
+// refreshLogServers 重新读取服务器列表并解析 RCON 地址
+func refreshLogServers() {
+	logServersOnce.Lock()
+	defer logServersOnce.Unlock()
+
+	servers := ListServers()
+	resolved := make([]logServer, 0, len(servers))
+	for _, s := range servers {
+		entry := logServer{server: s}
+		if host, port, err := net.SplitHostPort(s.RconURL); err == nil {
+			entry.port, _ = strconv.Atoi(port)
+			if ips, err := net.LookupIP(host); err == nil {
+				entry.ips = ips
+			} else {
+				log.Printf("解析服务器 %s 的 RCON 地址失败: %v", s.ID, err)
+			}
+		}
+		resolved = append(resolved, entry)
+	}
+
+	logServersMutex.Lock()
+	logServers = resolved
+	logServersMutex.Unlock()
+}
+
+// invalidateLogServers 服务器配置变更后在后台重新解析，未启用日志接收时不做任何事
+func invalidateLogServers() {
+	if os.Getenv(LogListenEnv) != "" {
+		go refreshLogServers()
+	}
+}
+
+// matchLogServer 优先按 sv_logsecret 匹配，否则按来源 IP 和端口与 RCON 地址匹配
+func matchLogServer(secret string, addr net.Addr) *GameServer {
+	logServersMutex.RLock()
+	servers := logServers
+	logServersMutex.RUnlock()
+
+	if secret != "" {
+		logSecretsMutex.Lock()
+		defer logSecretsMutex.Unlock()
+		for _, s := range servers {
+			if logSecrets[s.server.ID] == secret {
+				return s.server
+			}
+		}
+		return nil
+	}
+
+	udpAddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return nil
+	}
+	for _, s := range servers {
+		if s.port != udpAddr.Port {
+			continue
+		}
+		for _, ip := range s.ips {
+			if ip.Equal(udpAddr.IP) {
+				if logSecretApplied(s.server.ID) {
+					return nil
+				}
+				return s.server
+			}
+		}
+	}
+	return nil
+}
+
+func logSecretApplied(serverID string) bool {
+	logSecretsMutex.Lock()
+	defer logSecretsMutex.Unlock()
+	return logSecretsApplied[serverID]
+}
+
+func getLogSecret(serverID string) string {
+	logSecretsMutex.Lock()
+	defer logSecretsMutex.Unlock()
+
+	if secret, ok := logSecrets[serverID]; ok {
+		return secret
+	}
+	var b [4]byte
+	rand.Read(b[:])
+	secret := strconv.FormatUint(uint64(binary.BigEndian.Uint32(b[:])|1), 10)
+	logSecrets[serverID] = secret
+	return secret
+}
+
+// registerLogAddress 服务器重启后日志地址会丢失，所以需要定期重新注册
+func registerLogAddress(address string) {
+	for {
+		for _, s := range ListServers() {
+			client, err := s.Rcon()
+			if err != nil {
+				continue
+			}
+			commands := []string{
+				fmt.Sprintf("sv_logsecret %s", getLogSecret(s.ID)),
+				"log on",
+				"logaddress_add " + address,
+			}
+			for i, cmd := range commands {
+				if _, err := client.Execute(cmd); err != nil {
+					break
+				}
+				if i == 0 {
+					logSecretsMutex.Lock()
+					logSecretsApplied[s.ID] = true
+					logSecretsMutex.Unlock()
+				}
+			}
+		}
+		time.Sleep(logRegisterInterval)
+	}
+}
@@ -10,8 +10,11 @@ import (
 const ManagerConfigPath = "manager_config.json"
 
 type ManagerConfig struct {
-	EnableSelfService   bool      `json:"enable_self_service"`
-	LastSelfServiceTime time.Time `json:"last_self_service_time"`
+	EnableSelfService   bool         `json:"enable_self_service"`
+	LastSelfServiceTime time.Time    `json:"last_self_service_time"`
+	Servers             []GameServer `json:"servers"`
+	// DefaultLaunch 默认服务器的启动配置，为空时使用环境变量
+	DefaultLaunch *LaunchProfile `json:"default_launch,omitempty"`
 }
 
 var (
@@ -31,11 +34,7 @@ func LoadManagerConfig() {
 		EnableSelfService: false,
 	}
 
-	if _, err := os.Stat(ManagerConfigPath); os.IsNotExist(err) {
-		saveManagerConfig()
-		return
-	}
-
+	// 文件不存在时使用默认配置，第一次修改配置时才写入
 	data, err := os.ReadFile(ManagerConfigPath)
 	if err != nil {
 		return
@@ -0,0 +1,255 @@
+package logic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MapIndexPath = "map_index.json"
+	// 索引增加字段时递增，旧记录在下次扫描时重新解析
+	mapIndexVersion = 2
+)
+
+// MapIndexEntry 地图库中的一个 VPK
+type MapIndexEntry struct {
+	FileName string            `json:"file_name"`
+	Size     int64             `json:"size"`
+	ModTime  time.Time         `json:"mod_time"`
+	Hash     string            `json:"hash"` // sha256
+	Campaign string            `json:"campaign,omitempty"`
+	Chapters []MapIndexChapter `json:"chapters"`
+	Modes    []string          `json:"modes"` // 所有章节支持的模式
+	// addoninfo.txt 中的信息
+	Title       string `json:"title,omitempty"`
+	Author      string `json:"author,omitempty"`
+	Version     string `json:"version,omitempty"`
+	Description string `json:"description,omitempty"`
+	URL         string `json:"url,omitempty"`
+
+	UploadedAt *time.Time `json:"uploaded_at,omitempty"`
+	UploadedBy string     `json:"uploaded_by,omitempty"`
+	IndexedAt  time.Time  `json:"indexed_at"`
+	// IndexVersion 生成索引时的格式版本，低于 mapIndexVersion 的记录会重新解析
+	IndexVersion int    `json:"index_version"`
+	Error        string `json:"error,omitempty"` // 解析失败的原因，文件本身仍然保留在索引中
+}
+
+// MapIndexChapter 战役中的一个章节
+type MapIndexChapter struct {
+	Code  string   `json:"code"`
+	Title string   `json:"title"`
+	Modes []string `json:"modes"`
+}
+
+var (
+	// 服务器 ID -> 文件名 -> 索引
+	mapIndex      = make(map[string]map[string]*MapIndexEntry)
+	mapIndexMutex sync.Mutex
+	// 同一时间只做一次扫描，避免重复计算哈希
+	mapIndexRefreshMutex sync.Mutex
+)
+
+func init() {
+	LoadMapIndex()
+}
+
+// LoadMapIndex 读取地图索引
+func LoadMapIndex() {
+	mapIndexMutex.Lock()
+	defer mapIndexMutex.Unlock()
+
+	mapIndex = make(map[string]map[string]*MapIndexEntry)
+	data, err := os.ReadFile(MapIndexPath)
+	if err != nil {
+		return
+	}
+	if err := json.Unmarshal(data, &mapIndex); err != nil {
+		log.Printf("读取地图索引失败: %v", err)
+	}
+}
+
+func saveMapIndex() error {
+	data, err := json.Marshal(mapIndex)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(MapIndexPath, data, 0644)
+}
+
+// RecordMapUpload 记录地图的上传者，同名文件重新上传时覆盖之前的记录
+func RecordMapUpload(s *GameServer, fileName, uploader string) {
+	now := time.Now()
+	mapIndexMutex.Lock()
+	// 扫描时会在锁外读取旧的 map，这里复制一份再修改
+	entries := make(map[string]*MapIndexEntry, len(mapIndex[s.ID])+1)
+	for name, entry := range mapIndex[s.ID] {
+		entries[name] = entry
+	}
+	mapIndex[s.ID] = entries
+	// 只保留上传信息，其余字段在下次扫描时重新计算
+	entries[fileName] = &MapIndexEntry{
+		FileName:   fileName,
+		UploadedAt: &now,
+		UploadedBy: uploader,
+	}
+	if err := saveMapIndex(); err != nil {
+		log.Printf("保存地图索引失败: %v", err)
+	}
+	mapIndexMutex.Unlock()
+
+	go func() {
+		if _, err := RefreshMapIndex(s); err != nil {
+			log.Printf("更新服务器 %s 的地图索引失败: %v", s.ID, err)
+		}
+	}()
+}
+
+// GetMapIndex 返回服务器的地图库，返回前先增量更新
+func GetMapIndex(s *GameServer) ([]MapIndexEntry, error) {
+	return RefreshMapIndex(s)
+}
+
+// RefreshMapIndex 扫描 addons 目录，只重新解析大小或修改时间变化的 VPK，并移除已删除的文件
+func RefreshMapIndex(s *GameServer) ([]MapIndexEntry, error) {
+	mapIndexRefreshMutex.Lock()
+	defer mapIndexRefreshMutex.Unlock()
+
+	dirEntries, err := os.ReadDir(s.AddonsPath())
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %v", err)
+	}
+
+	mapIndexMutex.Lock()
+	old := mapIndex[s.ID]
+	mapIndexMutex.Unlock()
+
+	fresh := make(map[string]*MapIndexEntry)
+	changed := false
+	for _, e := range dirEntries {
+		if e.IsDir() || !strings.EqualFold(filepath.Ext(e.Name()), ".vpk") {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+
+		prev := old[e.Name()]
+		if prev != nil && prev.Hash != "" && prev.IndexVersion >= mapIndexVersion &&
+			prev.Size == info.Size() && prev.ModTime.Equal(info.ModTime()) {
+			fresh[e.Name()] = prev
+			continue
+		}
+
+		entry := indexVpk(filepath.Join(s.AddonsPath(), e.Name()), info)
+		if prev != nil {
+			entry.UploadedAt = prev.UploadedAt
+			entry.UploadedBy = prev.UploadedBy
+		}
+		fresh[e.Name()] = entry
+		changed = true
+	}
+	if len(fresh) != len(old) {
+		changed = true
+	}
+
+	mapIndexMutex.Lock()
+	// 扫描期间新上传的文件保留上传记录
+	for name, entry := range mapIndex[s.ID] {
+		if entry.Hash != "" || entry.UploadedAt == nil {
+			continue
+		}
+		if current, ok := fresh[name]; !ok {
+			fresh[name] = entry
+		} else if current.UploadedAt == nil || current.UploadedAt.Before(*entry.UploadedAt) {
+			copied := *current
+			copied.UploadedAt = entry.UploadedAt
+			copied.UploadedBy = entry.UploadedBy
+			fresh[name] = &copied
+		}
+	}
+	mapIndex[s.ID] = fresh
+	if changed {
+		if err := saveMapIndex(); err != nil {
+			log.Printf("保存地图索引失败: %v", err)
+		}
+	}
+	list := make([]MapIndexEntry, 0, len(fresh))
+	for _, entry := range fresh {
+		list = append(list, *entry)
+	}
+	mapIndexMutex.Unlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].FileName < list[j].FileName
+	})
+	return list, nil
+}
+
+// indexVpk 计算 VPK 的哈希并读取战役和 addoninfo 信息
+func indexVpk(path string, info os.FileInfo) *MapIndexEntry {
+	entry := &MapIndexEntry{
+		FileName:  filepath.Base(path),
+		Size:      info.Size(),
+		ModTime:   info.ModTime(),
+		Chapters:  []MapIndexChapter{},
+		Modes:     []string{},
+		IndexedAt: time.Now(),
+
+		IndexVersion: mapIndexVersion,
+	}
+
+	hash, err := hashFile(path)
+	if err != nil {
+		entry.Error = fmt.Sprintf("计算哈希失败: %v", err)
+		return entry
+	}
+	entry.Hash = hash
+
+	meta, err := readVpkMetadata(path)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	campaign, addonInfo := meta.campaign, meta.addon
+	if addonInfo != nil {
+		entry.Title = addonInfo.Title
+		entry.Author = addonInfo.Author
+		entry.Version = addonInfo.Version
+		entry.Description = addonInfo.Description
+		entry.URL = addonInfo.URL
+	}
+	if campaign != nil {
+		entry.Campaign = campaign.Title
+		for _, ch := range campaign.Chapters {
+			entry.Chapters = append(entry.Chapters, MapIndexChapter{Code: ch.Code, Title: ch.Title, Modes: ch.Modes})
+			entry.Modes = mergeUniqueModes(entry.Modes, ch.Modes)
+		}
+	}
+	return entry
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
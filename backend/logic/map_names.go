@@ -0,0 +1,65 @@
+package logic
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 官方战役的地图代码，位于游戏本体的 VPK 中
+var officialMaps = map[string]bool{
+	"c1m1_hotel": true, "c1m2_streets": true, "c1m3_mall": true, "c1m4_atrium": true,
+	"c2m1_highway": true, "c2m2_fairgrounds": true, "c2m3_coaster": true, "c2m4_barns": true, "c2m5_concert": true,
+	"c3m1_plankcountry": true, "c3m2_swamp": true, "c3m3_shantytown": true, "c3m4_plantation": true,
+	"c4m1_milltown_a": true, "c4m2_sugarmill_a": true, "c4m3_sugarmill_b": true, "c4m4_milltown_b": true, "c4m5_milltown_escape": true,
+	"c5m1_waterfront": true, "c5m2_park": true, "c5m3_cemetery": true, "c5m4_quarter": true, "c5m5_bridge": true,
+	"c6m1_riverbank": true, "c6m2_bedlam": true, "c6m3_port": true,
+	"c7m1_docks": true, "c7m2_barge": true, "c7m3_port": true,
+	"c8m1_apartment": true, "c8m2_subway": true, "c8m3_sewers": true, "c8m4_interior": true, "c8m5_rooftop": true,
+	"c9m1_alleys": true, "c9m2_lots": true,
+	"c10m1_caves": true, "c10m2_drainage": true, "c10m3_ranchhouse": true, "c10m4_mainstreet": true, "c10m5_houseboat": true,
+	"c11m1_greenhouse": true, "c11m2_offices": true, "c11m3_garage": true, "c11m4_terminal": true, "c11m5_runway": true,
+	"c12m1_hilltop": true, "c12m2_traintunnel": true, "c12m3_bridge": true, "c12m4_barn": true, "c12m5_cornfield": true,
+	"c13m1_alpinecreek": true, "c13m2_southpinestream": true, "c13m3_memorialbridge": true, "c13m4_cutthroatcreek": true,
+	"c14m1_junkyard": true, "c14m2_lighthouse": true,
+}
+
+// unsafeRconArg 参数中包含空格、引号、分号或换行时会被服务器拆成多条命令或多个参数
+func unsafeRconArg(arg string) bool {
+	return strings.ContainsAny(arg, " ;\"\r\n")
+}
+
+// ValidateMapName 检查地图名称不会注入其他命令，并且是官方地图、addons 中 VPK 包含的地图或 maps 目录中的地图
+func ValidateMapName(s *GameServer, name string) error {
+	if name == "" || unsafeRconArg(name) {
+		return fmt.Errorf("无效的地图名称")
+	}
+	code := strings.ToLower(name)
+	if officialMaps[code] {
+		return nil
+	}
+	if info, err := os.Stat(filepath.Join(s.GamePath, "maps", code+".bsp")); err == nil && !info.IsDir() {
+		return nil
+	}
+
+	entries, err := os.ReadDir(s.AddonsPath())
+	if err != nil {
+		return fmt.Errorf("读取目录失败: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), ".vpk") {
+			names = append(names, e.Name())
+		}
+	}
+	bsp := "maps/" + code + ".bsp"
+	for _, info := range loadVpkInfos(s, names) {
+		for _, file := range info.files {
+			if file == bsp {
+				return nil
+			}
+		}
+	}
+	return fmt.Errorf("地图 %s 不存在", name)
+}
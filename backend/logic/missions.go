@@ -1,100 +1,258 @@
 package logic
 
 import (
-	"bufio"
+	"fmt"
 	"io"
-	"l4d2-manager-next/consts"
 	"log"
 	"os"
 	"path/filepath"
-	"regexp"
+	"runtime"
 	"strings"
+	"sync"
+	"time"
 
 	"git.lubar.me/ben/valve/vpk"
+	"github.com/panjf2000/ants/v2"
 )
 
-// 获取章节列表
-func GetChapterList() []*Campaign {
-	temp := make([]*Campaign, 0, 16)
+// campaignCacheEntry 按文件大小和修改时间缓存的 VPK 解析结果
+type campaignCacheEntry struct {
+	size    int64
+	modTime time.Time
+	vpkMetadata
+}
+
+// vpkMetadata VPK 的解析结果
+// campaign 为 nil 表示没有任务文件，addon 为 nil 表示没有 addoninfo.txt
+type vpkMetadata struct {
+	campaign *Campaign
+	addon    *AddonInfo
+	files    []string // VPK 中所有文件的路径，统一为小写
+}
+
+var (
+	// VPK 文件路径 -> 解析结果
+	campaignCache      = make(map[string]*campaignCacheEntry)
+	campaignCacheMutex sync.RWMutex
+	// 所有请求共用，限制同时解析的 VPK 数量
+	campaignPool *ants.Pool
+)
+
+func init() {
+	var err error
+	if campaignPool, err = ants.NewPool(runtime.NumCPU()); err != nil {
+		panic(err)
+	}
+}
 
+// 获取章节列表，未变化的 VPK 直接使用缓存，其余的并行解析
+func GetChapterList(server *GameServer) []*Campaign {
 	// 扫描addons下的所有vpk文件
-	entries, err := os.ReadDir(filepath.Join(consts.AddonsBasePath))
+	addonsPath := server.AddonsPath()
+	entries, err := os.ReadDir(addonsPath)
 	if err != nil {
 		log.Printf("读取目录失败: %v", err)
 		return nil
 	}
+
+	var names []string
 	for _, entry := range entries {
 		if !entry.IsDir() && strings.HasSuffix(strings.ToLower(entry.Name()), ".vpk") {
-			opener := vpk.Single(filepath.Join(consts.AddonsBasePath, entry.Name()))
-			defer opener.Close()
+			names = append(names, entry.Name())
+		}
+	}
+	results := loadVpkInfos(server, names)
+
+	// 按目录顺序输出，相同标题的战役只保留第一个
+	temp := make([]*Campaign, 0, len(names))
+	seen := make(map[string]bool)
+	for _, name := range names {
+		result := results[name]
+		if result == nil || result.campaign == nil || seen[result.campaign.Title] {
+			continue
+		}
+		seen[result.campaign.Title] = true
+		temp = append(temp, result.campaign)
+	}
+	return temp
+}
 
-			archive, err := opener.ReadArchive()
-			if err != nil {
-				log.Printf("Failed to read VPK archive %s: %v", entry.Name(), err)
-				continue
-			}
+// GetAddonInfos 返回 addons 目录中指定 VPK 的 addoninfo.txt 信息，没有的文件不在结果中
+func GetAddonInfos(server *GameServer, names []string) map[string]*AddonInfo {
+	infos := make(map[string]*AddonInfo)
+	for name, result := range loadVpkInfos(server, names) {
+		if result.addon != nil {
+			infos[name] = result.addon
+		}
+	}
+	return infos
+}
 
-			// 查找所有 missions/*.txt 文件
-			var missionFiles []*vpk.File
-			for _, file := range archive.Files {
-				if strings.HasPrefix(file.Name(), "missions/") && strings.HasSuffix(file.Name(), ".txt") {
-					fileCopy := file
-					missionFiles = append(missionFiles, &fileCopy)
-				}
-			}
-			if len(missionFiles) == 0 {
-				log.Printf("在 VPK %s 中未找到任务文件", entry.Name())
-				continue
-			}
+// loadVpkInfos 按文件名读取 VPK 的解析结果，缓存失效的文件交给协程池并行解析，不存在的文件不在结果中
+func loadVpkInfos(server *GameServer, names []string) map[string]*campaignCacheEntry {
+	results := make(map[string]*campaignCacheEntry, len(names))
+	var resultsMutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, name := range names {
+		path := filepath.Join(server.AddonsPath(), name)
+		info, err := os.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
 
-			// 解析并合并所有 mission 文件
-			var campaign *Campaign
-			for i, missionFile := range missionFiles {
-				rc, err := missionFile.Open(opener)
-				if err != nil {
-					log.Printf("打开 vpk %s 中任务文件 %s 失败: %v", entry.Name(), missionFile.Name(), err)
-					continue
-				}
+		campaignCacheMutex.RLock()
+		cached, ok := campaignCache[path]
+		campaignCacheMutex.RUnlock()
+		if ok && cached.size == info.Size() && cached.modTime.Equal(info.ModTime()) {
+			results[name] = cached
+			continue
+		}
 
-				parsedCampaign, err := parseMissionFile(rc)
-				rc.Close()
-				if err != nil {
-					log.Printf("解析 %s 任务文件 %s 失败: %v", entry.Name(), missionFile.Name(), err)
-					continue
-				}
+		wg.Add(1)
+		err = campaignPool.Submit(func() {
+			defer wg.Done()
+			entry := &campaignCacheEntry{size: info.Size(), modTime: info.ModTime(), vpkMetadata: parseVpk(path)}
+			campaignCacheMutex.Lock()
+			campaignCache[path] = entry
+			campaignCacheMutex.Unlock()
+
+			resultsMutex.Lock()
+			results[name] = entry
+			resultsMutex.Unlock()
+		})
+		if err != nil {
+			wg.Done()
+			log.Printf("提交 VPK %s 解析任务失败: %v", name, err)
+		}
+	}
+	wg.Wait()
+	return results
+}
 
-				// 第一个文件作为基础
-				if i == 0 {
-					campaign = parsedCampaign
-				} else {
-					// 合并后续文件的章节和模式
-					campaign = mergeCampaigns(campaign, parsedCampaign)
-				}
-			}
+// parseVpk 解析 VPK 中的任务文件、addoninfo.txt 和文件列表，失败时返回空结果
+func parseVpk(path string) vpkMetadata {
+	name := filepath.Base(path)
+	meta, err := readVpkMetadata(path)
+	if err != nil {
+		log.Printf("解析 VPK %s 失败: %v", name, err)
+		return vpkMetadata{}
+	}
+	if meta.campaign == nil {
+		log.Printf("在 VPK %s 中未找到任务文件", name)
+		return *meta
+	}
+	meta.campaign.VpkName = name
+	meta.campaign.Addon = meta.addon
+	return *meta
+}
 
-			if campaign == nil {
-				log.Printf("VPK %s 中没有成功解析任何任务文件", entry.Name())
-				continue
-			}
+// readVpkMetadata 读取 VPK 的文件列表以及其中的 missions/*.txt 和 addoninfo.txt
+func readVpkMetadata(path string) (*vpkMetadata, error) {
+	opener := vpk.Single(path)
+	defer opener.Close()
 
-			campaign.VpkName = entry.Name()
+	archive, err := opener.ReadArchive()
+	if err != nil {
+		return nil, fmt.Errorf("读取 VPK 失败: %v", err)
+	}
 
-			// 如果已经存在相同的战役，则跳过
-			exist := false
-			for _, cam := range temp {
-				if cam.Title == campaign.Title {
-					exist = true
-					break
-				}
+	meta := &vpkMetadata{files: make([]string, 0, len(archive.Files))}
+	for i := range archive.Files {
+		file := &archive.Files[i]
+		name := strings.ToLower(file.Name())
+		meta.files = append(meta.files, name)
+		switch {
+		case strings.HasPrefix(name, "missions/") && strings.HasSuffix(name, ".txt"):
+			rc, err := file.Open(opener)
+			if err != nil {
+				log.Printf("打开 vpk %s 中任务文件 %s 失败: %v", filepath.Base(path), file.Name(), err)
+				continue
 			}
-			if exist {
+			parsed, err := parseMissionFile(rc)
+			rc.Close()
+			if err != nil {
+				log.Printf("解析 %s 任务文件 %s 失败: %v", filepath.Base(path), file.Name(), err)
+				continue
+			}
+			meta.campaign = mergeCampaigns(meta.campaign, parsed)
+		case name == "addoninfo.txt":
+			rc, err := file.Open(opener)
+			if err != nil {
 				continue
 			}
-			temp = append(temp, campaign)
+			meta.addon, err = parseAddonInfo(rc)
+			rc.Close()
+			if err != nil {
+				log.Printf("解析 %s 的 addoninfo.txt 失败: %v", filepath.Base(path), err)
+			}
 		}
 	}
+	return meta, nil
+}
 
-	return temp
+// AddonInfo VPK 根目录 addoninfo.txt 中的地图信息
+type AddonInfo struct {
+	Title       string `json:"title"`
+	Author      string `json:"author"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+	URL         string `json:"url"`
+}
+
+// parseAddonInfo 解析 addoninfo.txt，文件可能是 UTF-8、GBK 或 UTF-16 编码
+func parseAddonInfo(r io.Reader) (*AddonInfo, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	root, err := ParseKeyValues(strings.NewReader(decodeText(data)))
+	if err != nil {
+		return nil, err
+	}
+	// 键名不区分大小写，部分文件没有 AddonInfo 外层
+	block := root
+	if child := root.Child("AddonInfo"); child != nil && child.IsBlock {
+		block = child
+	}
+	info := &AddonInfo{
+		Title:       strings.TrimSpace(block.Get("addontitle")),
+		Author:      strings.TrimSpace(block.Get("addonauthor")),
+		Version:     strings.TrimSpace(block.Get("addonversion")),
+		Description: strings.TrimSpace(block.Get("addonDescription")),
+		URL:         strings.TrimSpace(block.Get("addonURL0")),
+	}
+	if *info == (AddonInfo{}) {
+		return nil, nil
+	}
+	return info, nil
+}
+
+// InvalidateCampaignCache 上传或删除地图后清除对应文件的缓存，fileName 为空时清除整个 addons 目录
+func InvalidateCampaignCache(server *GameServer, fileName string) {
+	campaignCacheMutex.Lock()
+	defer campaignCacheMutex.Unlock()
+
+	if fileName != "" {
+		delete(campaignCache, filepath.Join(server.AddonsPath(), fileName))
+		return
+	}
+	prefix := filepath.Clean(server.AddonsPath()) + string(filepath.Separator)
+	for path := range campaignCache {
+		if strings.HasPrefix(path, prefix) {
+			delete(campaignCache, path)
+		}
+	}
+}
+
+// WarmCampaignCache 启动时在后台解析所有服务器的地图，首次打开地图列表时不用等待
+func WarmCampaignCache() {
+	go func() {
+		for _, s := range ListServers() {
+			start := time.Now()
+			campaigns := GetChapterList(s)
+			log.Printf("服务器 %s 的战役列表已缓存: %d 个战役，耗时 %s", s.ID, len(campaigns), time.Since(start).Round(time.Millisecond))
+		}
+	}()
 }
 
 // mergeCampaigns 合并两个战役数据，将第二个战役的章节和模式合并到第一个中
@@ -155,6 +313,7 @@ type Campaign struct {
 	Title    string
 	Chapters []*Chapter
 	VpkName  string
+	Addon    *AddonInfo // addoninfo.txt 中的标题、作者等信息，没有时为 nil
 }
 
 type Chapter struct {
@@ -163,110 +322,68 @@ type Chapter struct {
 	Modes []string // 支持的游戏模式
 }
 
-// 使用正则表达式来更可靠地提取键值对
-var kvRegex = regexp.MustCompile(`"([^"]+)"\s+"([^"]+)"`)
-
+// parseMissionFile 解析 missions/*.txt，modes 下的每个块都是一种模式，
+// 模式中的每个子块是一个章节，因此突变和自定义模式也能识别
 func parseMissionFile(reader io.Reader) (*Campaign, error) {
-	scanner := bufio.NewScanner(reader)
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	root, err := ParseKeyValuesWith(strings.NewReader(decodeText(data)), KeyValuesOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	mission := root.Child("mission")
+	if mission == nil || !mission.IsBlock {
+		mission = root
+	}
 	campaign := &Campaign{
+		Title:    mission.Get("DisplayTitle"),
 		Chapters: make([]*Chapter, 0, 8), // 预分配容量
 	}
+	if campaign.Title == "" {
+		campaign.Title = mission.Get("Name")
+	}
 
-	inGameModeSection := false
-	braceLevel := 0
-	var tempMapName string
-	var currentMode string
+	modes := mission.Child("modes")
+	if modes == nil || !modes.IsBlock {
+		return campaign, nil
+	}
 	seenChapters := make(map[string]*Chapter) // 用于去重和追加模式
-
-	for scanner.Scan() {
-		line := scanner.Text()
-
-		if len(line) == 0 {
-			continue
-		}
-
-		line = strings.TrimSpace(line)
-		if strings.HasPrefix(line, "//") {
-			continue
-		}
-
-		// 状态机，用于进入/退出游戏模式部分
-		// 支持多种游戏模式：coop, survival, halftank, brawler 等
-		lowerLine := strings.ToLower(line)
-
-		// 移除行内注释（//之后的内容）
-		if commentIndex := strings.Index(lowerLine, "//"); commentIndex != -1 {
-			lowerLine = strings.TrimSpace(lowerLine[:commentIndex])
-		}
-
-		if !inGameModeSection && (lowerLine == `"coop"` || lowerLine == `"survival"` ||
-			lowerLine == `"halftank"` || lowerLine == `"brawler"` || lowerLine == `"versus"` ||
-			lowerLine == `"scavenge"` || lowerLine == `"realism"`) {
-			inGameModeSection = true
-			braceLevel = 0
-			// 提取模式名称，去除引号
-			currentMode = strings.Trim(lowerLine, `"`)
+	for _, modeNode := range modes.Children {
+		if !modeNode.IsBlock {
 			continue
 		}
-
-		if inGameModeSection {
-			for _, char := range line {
-				switch char {
-				case '{':
-					braceLevel++
-				case '}':
-					braceLevel--
-				}
+		mode := strings.ToLower(modeNode.Key)
+		for _, chapterNode := range modeNode.Children {
+			if !chapterNode.IsBlock {
+				continue
 			}
-
-			// 如果 braceLevel 降为 0，说明已退出游戏模式部分
-			// 我们检查 braceLevel <= 0 是为了防止文件格式不规范
-			if braceLevel <= 0 {
-				inGameModeSection = false
-				currentMode = ""
+			code := chapterNode.Get("Map")
+			if code == "" {
 				continue
 			}
-		}
-
-		// 只在必要时使用正则表达式
-		if strings.Contains(line, `"`) {
-			matches := kvRegex.FindStringSubmatch(line)
-			if len(matches) == 3 {
-				key := strings.ToLower(matches[1])
-				value := matches[2]
-
-				// 始终在文件顶层查找战役标题
-				if key == "displaytitle" && campaign.Title == "" {
-					campaign.Title = value
-				}
-
-				// 如果在游戏模式区域内
-				if inGameModeSection {
-					if key == "map" {
-						tempMapName = value
-					}
-
-					if key == "displayname" && tempMapName != "" {
-						// 检查是否已经添加过这个章节
-						if chapter, exists := seenChapters[tempMapName]; exists {
-							// 已存在，添加模式到该章节
-							chapter.Modes = append(chapter.Modes, currentMode)
-						} else {
-							// 不存在，创建新章节
-							chapter := &Chapter{
-								Code:  tempMapName,
-								Title: value,
-								Modes: []string{currentMode},
-							}
-							campaign.Chapters = append(campaign.Chapters, chapter)
-							seenChapters[tempMapName] = chapter
-						}
-						tempMapName = "" // 重置
-					}
+			if chapter, exists := seenChapters[code]; exists {
+				// 已存在，添加模式到该章节
+				chapter.Modes = mergeUniqueModes(chapter.Modes, []string{mode})
+				if chapter.Title == code && chapterNode.Get("DisplayName") != "" {
+					chapter.Title = chapterNode.Get("DisplayName")
 				}
+				continue
 			}
+			title := chapterNode.Get("DisplayName")
+			if title == "" {
+				title = code
+			}
+			chapter := &Chapter{
+				Code:  code,
+				Title: title,
+				Modes: []string{mode},
+			}
+			campaign.Chapters = append(campaign.Chapters, chapter)
+			seenChapters[code] = chapter
 		}
 	}
-
-	return campaign, scanner.Err()
+	return campaign, nil
 }
@@ -0,0 +1,123 @@
+package logic
+
+import (
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// 权限点，路由通过 middlewares.Require 声明所需权限
+const (
+	PermStatusView     = "status.view"
+	PermMonitorView    = "monitor.view"
+	PermMapsView       = "maps.view"
+	PermMapsChange     = "maps.change"
+	PermMapsUpload     = "maps.upload"
+	PermMapsDownload   = "maps.download"
+	PermMapsDelete     = "maps.delete"
+	PermGameSettings   = "game.settings"
+	PermCvarsView      = "cvars.view"
+	PermCvarsEdit      = "cvars.edit"
+	PermPlayersView    = "players.view"
+	PermPlayersKick    = "players.kick"
+	PermPlayersBan     = "players.ban"
+	PermPlayersIPs     = "players.ips" // 玩家历史记录中的 IP
+	PermRconRaw        = "rcon.raw"
+	PermConsoleView    = "console.view"
+	PermServerRestart  = "server.restart"
+	PermServerInfoView = "serverinfo.view"
+	PermServerInfoEdit = "serverinfo.edit"
+	PermServerCfgEdit  = "servercfg.edit" // server.cfg 中包含 rcon_password，查看也需要此权限
+	PermPluginsView    = "plugins.view"
+	PermPluginsUpload  = "plugins.upload"
+	PermPluginsEnable  = "plugins.enable"
+	PermPluginsDelete  = "plugins.delete"
+	PermPluginsConfig  = "plugins.config"
+	PermAdminsView     = "admins.view"
+	PermAdminsEdit     = "admins.edit"
+	PermServersView    = "servers.view"
+	PermServersManage  = "servers.manage"
+	PermUsersManage    = "users.manage"
+	PermAuthGuest      = "auth.guest"
+	PermSettingsEdit   = "settings.edit"
+	PermAuditView      = "audit.view"
+	PermScheduleView   = "schedule.view"
+	PermScheduleManage = "schedule.manage"
+
+	PermAll = "*"
+)
+
+// AllPermissions 所有可分配的权限
+var AllPermissions = []string{
+	PermStatusView, PermMonitorView,
+	PermMapsView, PermMapsChange, PermMapsUpload, PermMapsDownload, PermMapsDelete,
+	PermGameSettings, PermCvarsView, PermCvarsEdit,
+	PermPlayersView, PermPlayersKick, PermPlayersBan, PermPlayersIPs,
+	PermRconRaw, PermConsoleView, PermServerRestart,
+	PermServerInfoView, PermServerInfoEdit, PermServerCfgEdit,
+	PermPluginsView, PermPluginsUpload, PermPluginsEnable, PermPluginsDelete, PermPluginsConfig,
+	PermAdminsView, PermAdminsEdit,
+	PermServersView, PermServersManage,
+	PermUsersManage, PermAuthGuest, PermSettingsEdit,
+	PermAuditView,
+	PermScheduleView, PermScheduleManage,
+}
+
+var viewerPermissions = []string{
+	PermStatusView, PermMonitorView, PermMapsView, PermPlayersView,
+	PermServerInfoView, PermPluginsView, PermAdminsView, PermServersView,
+}
+
+var operatorPermissions = append([]string{
+	PermMapsChange, PermMapsUpload, PermMapsDownload, PermGameSettings,
+	PermPlayersKick, PermPlayersBan, PermPlayersIPs, PermServerRestart, PermConsoleView,
+	PermScheduleView, PermCvarsView,
+}, viewerPermissions...)
+
+// DefaultGuestPermissions 未指定权限的临时授权码拥有的权限
+var DefaultGuestPermissions = append([]string{
+	PermMapsChange, PermMapsUpload, PermMapsDownload, PermGameSettings,
+}, viewerPermissions...)
+
+var rolePermissions = map[string][]string{
+	RoleOwner:    {PermAll},
+	RoleAdmin:    {PermAll},
+	RoleOperator: operatorPermissions,
+	RoleViewer:   viewerPermissions,
+	RoleGuest:    DefaultGuestPermissions,
+}
+
+// GuestClaims 临时授权码，ID 对应授权码登记表中的记录，Permissions 为空时使用默认访客权限
+type GuestClaims struct {
+	Label       string   `json:"label,omitempty"`
+	Permissions []string `json:"perms,omitempty"`
+	jwt.RegisteredClaims
+}
+
+// RolePermissions 返回角色拥有的权限
+func RolePermissions(role string) []string {
+	return rolePermissions[role]
+}
+
+// HasPermission 判断权限列表是否包含 perm，支持 "*" 和 "maps.*" 形式的通配符
+func HasPermission(perms []string, perm string) bool {
+	for _, p := range perms {
+		if p == PermAll || p == perm {
+			return true
+		}
+		if strings.HasSuffix(p, ".*") && strings.HasPrefix(perm, strings.TrimSuffix(p, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidPermission 是否为已定义的权限
+func IsValidPermission(perm string) bool {
+	for _, p := range AllPermissions {
+		if p == perm {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,451 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"l4d2-manager-next/steamid"
+)
+
+const (
+	PlayersFilePath = "players.json"
+
+	playerSyncInterval  = time.Minute
+	playerSaveInterval  = 30 * time.Second
+	playerMaxSessions   = 50 // 每个玩家保留的最近会话数
+	playerMaxNames      = 20
+	playerMaxIPs        = 20
+	defaultPlayerSearch = 50
+)
+
+// PlayerSession 玩家在某个服务器上的一次游戏会话，End 为空表示仍在线
+type PlayerSession struct {
+	ServerID string     `json:"server_id"`
+	Name     string     `json:"name"`
+	IP       string     `json:"ip"`
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"`
+	Seconds  int64      `json:"seconds"`
+}
+
+// PlayerRecord 一名玩家的历史记录
+type PlayerRecord struct {
+	SteamID      string          `json:"steamid"`
+	Name         string          `json:"name"`
+	Names        []string        `json:"names"`
+	IPs          []string        `json:"ips"`
+	FirstSeen    time.Time       `json:"first_seen"`
+	LastSeen     time.Time       `json:"last_seen"`
+	TotalSeconds int64           `json:"total_seconds"`
+	SessionCount int             `json:"session_count"`
+	Kicks        int             `json:"kicks"`
+	Bans         int             `json:"bans"`
+	Sessions     []PlayerSession `json:"sessions,omitempty"`
+}
+
+// PlayerSummary 搜索结果中的玩家概要，不包含会话明细
+type PlayerSummary struct {
+	SteamID      string    `json:"steamid"`
+	Name         string    `json:"name"`
+	FirstSeen    time.Time `json:"first_seen"`
+	LastSeen     time.Time `json:"last_seen"`
+	TotalSeconds int64     `json:"total_seconds"`
+	SessionCount int       `json:"session_count"`
+	Kicks        int       `json:"kicks"`
+	Bans         int       `json:"bans"`
+	Online       bool      `json:"online"`
+}
+
+var (
+	players      map[string]*PlayerRecord
+	playersDirty bool
+	playersMutex sync.Mutex
+)
+
+func init() {
+	LoadPlayers()
+}
+
+// LoadPlayers 读取玩家记录，上次退出时仍在线的会话按最后出现时间结束
+func LoadPlayers() {
+	playersMutex.Lock()
+	defer playersMutex.Unlock()
+
+	players = make(map[string]*PlayerRecord)
+	data, err := os.ReadFile(PlayersFilePath)
+	if err != nil {
+		return
+	}
+	var list []*PlayerRecord
+	if err := json.Unmarshal(data, &list); err != nil {
+		log.Printf("读取玩家记录失败: %v", err)
+		return
+	}
+	for _, p := range list {
+		p.SteamID = steamid.Normalize(p.SteamID)
+		players[p.SteamID] = p
+		for i := range p.Sessions {
+			if p.Sessions[i].End == nil {
+				closeSession(p, &p.Sessions[i], p.LastSeen)
+				playersDirty = true
+			}
+		}
+	}
+}
+
+func savePlayers() error {
+	list := make([]*PlayerRecord, 0, len(players))
+	for _, p := range players {
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].FirstSeen.Before(list[j].FirstSeen) })
+
+	data, err := json.Marshal(list)
+	if err != nil {
+		return err
+	}
+	playersDirty = false
+	return os.WriteFile(PlayersFilePath, data, 0600)
+}
+
+// StartPlayerTracker 根据日志事件和状态缓存的玩家变化记录玩家会话，并定期与状态缓存同步
+func StartPlayerTracker() {
+	events, _ := SubscribeEvents(EventPlayerConnect, EventPlayerDisconnect, EventPlayerKick, EventPlayerBan,
+		EventStatusPlayerJoin, EventStatusPlayerLeave)
+	go func() {
+		for e := range events {
+			handlePlayerEvent(e)
+		}
+	}()
+
+	go func() {
+		for {
+			for _, s := range ListServers() {
+				syncPlayers(s)
+			}
+			time.Sleep(playerSyncInterval)
+		}
+	}()
+
+	go func() {
+		for range time.Tick(playerSaveInterval) {
+			playersMutex.Lock()
+			if playersDirty {
+				if err := savePlayers(); err != nil {
+					log.Printf("保存玩家记录失败: %v", err)
+				}
+			}
+			playersMutex.Unlock()
+		}
+	}()
+}
+
+func handlePlayerEvent(e Event) {
+	playersMutex.Lock()
+	defer playersMutex.Unlock()
+
+	// 日志中新版本为 [U:1:N]，旧版本为 STEAM_1:Y:Z，统一后作为玩家记录的键
+	switch e := e.(type) {
+	case PlayerConnectEvent:
+		// 还未通过验证的玩家 (STEAM_ID_PENDING、STEAM_ID_LAN) 不记录
+		id, err := steamid.Parse(e.Player.SteamID)
+		if e.Player.IsBot() || err != nil {
+			return
+		}
+		ip := addressHost(e.Address)
+		openSession(e.ServerID, id.SteamID2(), e.Player.Name, ip, e.Time)
+	case PlayerDisconnectEvent:
+		if p, ok := players[steamid.Normalize(e.Player.SteamID)]; ok {
+			if session := findOpenSession(p, e.ServerID); session != nil {
+				closeSession(p, session, e.Time)
+			}
+		}
+	case StatusPlayerEvent:
+		// 机器人和还未通过验证的玩家 (STEAM_ID_PENDING) 没有有效的 SteamID
+		if e.Player.IsBot || e.Player.SteamId64 == "" {
+			return
+		}
+		id := steamid.Normalize(e.Player.SteamId)
+		if e.Type == EventStatusPlayerJoin {
+			start := e.Time.Add(-parseStatusDuration(e.Player.Duration))
+			openSession(e.ServerID, id, e.Player.Name, addressHost(e.Player.Ip), start)
+			players[id].LastSeen = e.Time
+		} else if p, ok := players[id]; ok {
+			if session := findOpenSession(p, e.ServerID); session != nil {
+				closeSession(p, session, e.Time)
+			}
+		}
+	case PlayerKickEvent:
+		if p, ok := players[steamid.Normalize(e.Player.SteamID)]; ok {
+			p.Kicks++
+			playersDirty = true
+		}
+	case PlayerBanEvent:
+		if p, ok := players[steamid.Normalize(e.Player.SteamID)]; ok && e.Player.SteamID != "" {
+			p.Bans++
+			playersDirty = true
+		}
+	}
+}
+
+// RecordPlayerKick 记录通过管理器踢出的玩家
+// 启用日志接收时由日志中的 Kick 事件计数，这里不重复记录
+func RecordPlayerKick(steamID string) {
+	recordPlayerAction(steamID, false)
+}
+
+// RecordPlayerBan 记录通过管理器封禁的玩家，规则同 RecordPlayerKick
+func RecordPlayerBan(steamID string) {
+	recordPlayerAction(steamID, true)
+}
+
+func recordPlayerAction(steamID string, ban bool) {
+	if os.Getenv(LogListenEnv) != "" || steamID == "" {
+		return
+	}
+	playersMutex.Lock()
+	defer playersMutex.Unlock()
+	p, ok := players[steamid.Normalize(steamID)]
+	if !ok {
+		return
+	}
+	if ban {
+		p.Bans++
+	} else {
+		p.Kicks++
+	}
+	playersDirty = true
+}
+
+// syncPlayers 与状态缓存同步在线玩家，补全丢失的上线和下线事件
+func syncPlayers(s *GameServer) {
+	status, err := GetCachedStatus(s)
+	if err != nil || status.Stale {
+		// 查询失败不代表玩家离开，保留会话等下一次成功的轮询
+		return
+	}
+	now := status.LastUpdated
+
+	playersMutex.Lock()
+	defer playersMutex.Unlock()
+
+	online := make(map[string]bool)
+	for _, u := range status.Users {
+		if u.IsBot || u.SteamId64 == "" {
+			continue
+		}
+		id := steamid.Normalize(u.SteamId)
+		online[id] = true
+		ip := addressHost(u.Ip)
+		start := now.Add(-parseStatusDuration(u.Duration))
+		openSession(s.ID, id, u.Name, ip, start)
+		players[id].LastSeen = now
+	}
+
+	// 不在列表中的玩家视为已下线
+	for _, p := range players {
+		if online[p.SteamID] {
+			continue
+		}
+		if session := findOpenSession(p, s.ID); session != nil {
+			closeSession(p, session, p.LastSeen)
+		}
+	}
+}
+
+// openSession 开始一次会话，已有未结束的会话时只更新信息
+func openSession(serverID, steamID, name, ip string, start time.Time) {
+	p, ok := players[steamID]
+	if !ok {
+		p = &PlayerRecord{SteamID: steamID, FirstSeen: start}
+		players[steamID] = p
+	}
+	p.Name = name
+	p.Names = appendRecent(p.Names, name, playerMaxNames)
+	if ip != "" {
+		p.IPs = appendRecent(p.IPs, ip, playerMaxIPs)
+	}
+	if p.LastSeen.Before(start) {
+		p.LastSeen = start
+	}
+	playersDirty = true
+
+	if findOpenSession(p, serverID) != nil {
+		return
+	}
+	p.SessionCount++
+	p.Sessions = append(p.Sessions, PlayerSession{ServerID: serverID, Name: name, IP: ip, Start: start})
+	if len(p.Sessions) > playerMaxSessions {
+		p.Sessions = p.Sessions[len(p.Sessions)-playerMaxSessions:]
+	}
+}
+
+func findOpenSession(p *PlayerRecord, serverID string) *PlayerSession {
+	for i := len(p.Sessions) - 1; i >= 0; i-- {
+		if p.Sessions[i].ServerID == serverID && p.Sessions[i].End == nil {
+			return &p.Sessions[i]
+		}
+	}
+	return nil
+}
+
+func closeSession(p *PlayerRecord, session *PlayerSession, end time.Time) {
+	if end.Before(session.Start) {
+		end = session.Start
+	}
+	session.End = &end
+	session.Seconds = int64(end.Sub(session.Start).Seconds())
+	p.TotalSeconds += session.Seconds
+	if p.LastSeen.Before(end) {
+		p.LastSeen = end
+	}
+	playersDirty = true
+}
+
+// appendRecent 把 value 移到列表末尾，超出上限时丢弃最早的
+func appendRecent(list []string, value string, max int) []string {
+	for i, v := range list {
+		if v == value {
+			list = append(list[:i], list[i+1:]...)
+			break
+		}
+	}
+	list = append(list, value)
+	if len(list) > max {
+		list = list[len(list)-max:]
+	}
+	return list
+}
+
+// addressHost 去掉地址中的端口，支持 [IPv6]:port，loopback 等非 IP 地址返回空
+func addressHost(address string) string {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		host = address
+	}
+	if net.ParseIP(host) == nil {
+		return ""
+	}
+	return host
+}
+
+// parseStatusDuration 解析 status 中的在线时长，格式为 mm:ss 或 hh:mm:ss
+func parseStatusDuration(s string) time.Duration {
+	var seconds int
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0
+		}
+		seconds = seconds*60 + n
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// SearchPlayers 按 SteamID、曾用名或 IP 搜索玩家，按最后出现时间倒序
+// withIPs 为 false 时不按 IP 匹配
+func SearchPlayers(query string, offset, limit int, withIPs bool) ([]PlayerSummary, int) {
+	playersMutex.Lock()
+	defer playersMutex.Unlock()
+
+	// 查询内容是完整的 SteamID 时按同一玩家精确匹配，支持任意格式
+	if id, err := steamid.Parse(query); err == nil {
+		query = id.SteamID2()
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+	matched := make([]PlayerSummary, 0)
+	for _, p := range players {
+		if query != "" && !playerMatches(p, query, withIPs) {
+			continue
+		}
+		matched = append(matched, PlayerSummary{
+			SteamID:      p.SteamID,
+			Name:         p.Name,
+			FirstSeen:    p.FirstSeen,
+			LastSeen:     p.LastSeen,
+			TotalSeconds: p.TotalSeconds,
+			SessionCount: p.SessionCount,
+			Kicks:        p.Kicks,
+			Bans:         p.Bans,
+			Online:       hasOpenSession(p),
+		})
+	}
+	sort.Slice(matched, func(i, j int) bool { return matched[i].LastSeen.After(matched[j].LastSeen) })
+
+	total := len(matched)
+	if limit <= 0 {
+		limit = defaultPlayerSearch
+	}
+	if offset >= total {
+		return []PlayerSummary{}, total
+	}
+	matched = matched[offset:]
+	if len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched, total
+}
+
+func playerMatches(p *PlayerRecord, query string, withIPs bool) bool {
+	if strings.Contains(strings.ToLower(p.SteamID), query) {
+		return true
+	}
+	for _, name := range p.Names {
+		if strings.Contains(strings.ToLower(name), query) {
+			return true
+		}
+	}
+	if !withIPs {
+		return false
+	}
+	for _, ip := range p.IPs {
+		if strings.HasPrefix(ip, query) {
+			return true
+		}
+	}
+	return false
+}
+
+func hasOpenSession(p *PlayerRecord) bool {
+	for i := range p.Sessions {
+		if p.Sessions[i].End == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// GetPlayer 获取玩家详情，在线会话的时长计算到当前时间
+func GetPlayer(steamID string) (*PlayerRecord, error) {
+	playersMutex.Lock()
+	defer playersMutex.Unlock()
+
+	p, ok := players[steamid.Normalize(steamID)]
+	if !ok {
+		return nil, fmt.Errorf("没有玩家 %s 的记录", steamID)
+	}
+
+	copied := *p
+	copied.Names = append([]string(nil), p.Names...)
+	copied.IPs = append([]string(nil), p.IPs...)
+	copied.Sessions = append([]PlayerSession(nil), p.Sessions...)
+	now := time.Now()
+	for i := range copied.Sessions {
+		if copied.Sessions[i].End == nil {
+			seconds := int64(now.Sub(copied.Sessions[i].Start).Seconds())
+			copied.Sessions[i].Seconds = seconds
+			copied.TotalSeconds += seconds
+		}
+	}
+	// 最近的会话排在前面
+	sort.Slice(copied.Sessions, func(i, j int) bool { return copied.Sessions[i].Start.After(copied.Sessions[j].Start) })
+	return &copied, nil
+}
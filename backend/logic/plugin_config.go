@@ -2,18 +2,17 @@ package logic
 
 import (
 	"fmt"
-	"l4d2-manager-next/consts"
 	"os"
 	"path/filepath"
 	"strings"
 )
 
-func GetPluginConfigs(pluginName string) ([]PluginConfigFile, error) {
+func GetPluginConfigs(server *GameServer, pluginName string) ([]PluginConfigFile, error) {
 	// 1. Check if plugin is enabled (optional, but good for validation)
 	// We can skip this and just look for files if we assume the UI only calls this for enabled plugins.
 	// But let's check the store path to find what SMX files belong to this plugin.
 
-	storePath := getStorePath()
+	storePath := server.PluginStore()
 
 	// Check multiple possible locations for plugins
 	possiblePaths := []string{
@@ -40,7 +39,7 @@ func GetPluginConfigs(pluginName string) ([]PluginConfigFile, error) {
 				}
 
 				// Check if cfg exists in server cfg/sourcemod
-				cfgPath := filepath.Join(consts.GamePath, "cfg", "sourcemod", cfgName)
+				cfgPath := filepath.Join(server.GamePath, "cfg", "sourcemod", cfgName)
 
 				if _, err := os.Stat(cfgPath); err == nil {
 					// Parse it
@@ -63,13 +62,13 @@ func GetPluginConfigs(pluginName string) ([]PluginConfigFile, error) {
 	return configs, nil
 }
 
-func SavePluginConfig(configName string, updates map[string]string) error {
+func SavePluginConfig(server *GameServer, configName string, updates map[string]string) error {
 	// Security check: configName should be just a filename, no paths
 	if strings.Contains(configName, "/") || strings.Contains(configName, "\\") {
 		return fmt.Errorf("invalid config name")
 	}
 
-	cfgPath := filepath.Join(consts.GamePath, "cfg", "sourcemod", configName)
+	cfgPath := filepath.Join(server.GamePath, "cfg", "sourcemod", configName)
 	if _, err := os.Stat(cfgPath); os.IsNotExist(err) {
 		return fmt.Errorf("config file not found")
 	}
@@ -4,7 +4,6 @@ import (
 	"archive/zip"
 	"fmt"
 	"io"
-	"l4d2-manager-next/consts"
 	"os"
 	"path/filepath"
 	"runtime"
@@ -41,11 +40,6 @@ type PluginConfig struct {
 	Files []string `mapstructure:"files"`
 }
 
-func init() {
-	configViper = viper.New()
-	configViper.SetConfigType("yaml")
-}
-
 func getStorePath() string {
 	path := os.Getenv(PluginStorePathEnv)
 	if path == "" {
@@ -66,31 +60,47 @@ func getStorePath() string {
 	return path
 }
 
-func getConfigPath() string {
+// PluginStore returns the plugin store of the server, falling back to the shared store
+func (s *GameServer) PluginStore() string {
+	if s.PluginStorePath != "" {
+		return s.PluginStorePath
+	}
+	return getStorePath()
+}
+
+func getConfigPath(server *GameServer) string {
 	// Store config in plugins path as requested
-	return filepath.Join(getStorePath(), ConfigFileName)
+	// Servers sharing one store keep their enabled lists in separate files
+	if server.ID == DefaultServerID {
+		return filepath.Join(server.PluginStore(), ConfigFileName)
+	}
+	return filepath.Join(server.PluginStore(), "plugins_"+server.ID+".yaml")
 }
 
-func loadConfig() error {
-	configViper.SetConfigFile(getConfigPath())
+func loadConfig(server *GameServer) error {
+	configPath := getConfigPath(server)
+	// Use a fresh instance so values Set for one server never leak into another
+	configViper = viper.New()
+	configViper.SetConfigType("yaml")
+	configViper.SetConfigFile(configPath)
 	// Create file if not exists
-	if _, err := os.Stat(getConfigPath()); os.IsNotExist(err) {
-		os.MkdirAll(filepath.Dir(getConfigPath()), 0755)
-		os.Create(getConfigPath())
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		os.MkdirAll(filepath.Dir(configPath), 0755)
+		os.Create(configPath)
 	}
 	return configViper.ReadInConfig()
 }
 
-func GetPlugins() ([]Plugin, error) {
+func GetPlugins(server *GameServer) ([]Plugin, error) {
 	pluginMutex.Lock()
 	defer pluginMutex.Unlock()
 
-	if err := loadConfig(); err != nil {
+	if err := loadConfig(server); err != nil {
 		// It's okay if config doesn't exist or is empty initially
 		// fmt.Println("Error loading config:", err)
 	}
 
-	storePath := getStorePath()
+	storePath := server.PluginStore()
 	entries, err := os.ReadDir(storePath)
 	if err != nil {
 		// Check if it's just not existing
@@ -163,7 +173,7 @@ func decodeZipName(name string) string {
 	return name
 }
 
-func UploadPlugin(file io.ReaderAt, size int64, filename string) error {
+func UploadPlugin(server *GameServer, file io.ReaderAt, size int64, filename string) error {
 	pluginMutex.Lock()
 	defer pluginMutex.Unlock()
 
@@ -203,7 +213,7 @@ func UploadPlugin(file io.ReaderAt, size int64, filename string) error {
 		}
 	}
 
-	storePath := getStorePath()
+	storePath := server.PluginStore()
 
 	if isSinglePlugin {
 		pluginName := strings.TrimSuffix(filename, filepath.Ext(filename))
@@ -349,11 +359,11 @@ func extractFiles(files []*zip.File, destDir string, stripPrefix string, decoded
 	return nil
 }
 
-func EnablePlugin(name string) error {
+func EnablePlugin(server *GameServer, name string) error {
 	pluginMutex.Lock()
 	defer pluginMutex.Unlock()
 
-	if err := loadConfig(); err != nil {
+	if err := loadConfig(server); err != nil {
 		// ignore
 	}
 
@@ -368,13 +378,13 @@ func EnablePlugin(name string) error {
 		}
 	}
 
-	storePath := getStorePath()
+	storePath := server.PluginStore()
 	pluginDir := filepath.Join(storePath, name, "left4dead2")
 	if _, err := os.Stat(pluginDir); os.IsNotExist(err) {
 		return fmt.Errorf("plugin directory not found or invalid structure")
 	}
 
-	gamePath := consts.GamePath
+	gamePath := server.GamePath
 
 	// Initialize plugin config
 	newPlugin := PluginConfig{
@@ -465,11 +475,11 @@ func EnablePlugin(name string) error {
 	return configViper.WriteConfig()
 }
 
-func DisablePlugin(name string) error {
+func DisablePlugin(server *GameServer, name string) error {
 	pluginMutex.Lock()
 	defer pluginMutex.Unlock()
 
-	if err := loadConfig(); err != nil {
+	if err := loadConfig(server); err != nil {
 		return err
 	}
 
@@ -493,7 +503,7 @@ func DisablePlugin(name string) error {
 		return fmt.Errorf("plugin %s is not enabled", name)
 	}
 
-	gamePath := consts.GamePath
+	gamePath := server.GamePath
 
 	for _, relPath := range targetPlugin.Files {
 		destPath := filepath.Join(gamePath, relPath)
@@ -509,26 +519,34 @@ func DisablePlugin(name string) error {
 	return configViper.WriteConfig()
 }
 
-func DeletePlugin(name string) error {
+func DeletePlugin(server *GameServer, name string) error {
 	pluginMutex.Lock()
 	defer pluginMutex.Unlock()
 
-	if err := loadConfig(); err != nil {
-		// ignore
-	}
+	storePath := server.PluginStore()
 
-	var enabledPlugins []PluginConfig
-	if err := configViper.UnmarshalKey(PluginsKey, &enabledPlugins); err != nil {
-		// ignore
-	}
+	// The store may be shared, so the plugin must be disabled on every server using it
+	for _, s := range ListServers() {
+		if s.PluginStore() != storePath {
+			continue
+		}
 
-	for _, p := range enabledPlugins {
-		if p.Name == name {
-			return fmt.Errorf("cannot delete enabled plugin, disable it first")
+		if err := loadConfig(s); err != nil {
+			// ignore
+		}
+
+		var enabledPlugins []PluginConfig
+		if err := configViper.UnmarshalKey(PluginsKey, &enabledPlugins); err != nil {
+			// ignore
+		}
+
+		for _, p := range enabledPlugins {
+			if p.Name == name {
+				return fmt.Errorf("cannot delete enabled plugin, disable it first (server: %s)", s.ID)
+			}
 		}
 	}
 
-	storePath := getStorePath()
 	pluginDir := filepath.Join(storePath, name)
 
 	return os.RemoveAll(pluginDir)
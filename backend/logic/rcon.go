@@ -0,0 +1,215 @@
+package logic
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorcon/rcon"
+)
+
+const (
+	RconIdleTimeoutEnv = "L4D2_RCON_IDLE_TIMEOUT"
+
+	defaultRconIdleTimeout  = 5 * time.Minute
+	rconHealthCheckInterval = 30 * time.Second
+	rconDialTimeout         = 5 * time.Second
+	rconCommandDeadline     = 10 * time.Second
+	rconMaxBackoff          = time.Minute
+)
+
+// 参数中包含密码的命令，写入审计日志和控制台时只保留命令名
+var sensitiveRconCommands = []string{"rcon_password", "sv_password", "sm_addadmin", "sm_admin", "sm_rcon", "rcon"}
+
+// RconClient 维护到游戏服务器的 RCON 长连接
+// 所有命令通过互斥锁串行执行，连接断开后自动重连，连续失败时指数退避，
+// 避免频繁认证触发 srcds 的 RCON 封禁
+type RconClient struct {
+	url         string
+	password    string
+	idleTimeout time.Duration
+
+	mu         sync.Mutex
+	conn       *rcon.Conn
+	lastUsed   time.Time
+	failures   int
+	lastErr    error
+	nextDialAt time.Time
+
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRconClient 创建 RCON 客户端并启动后台健康检查，连接在首次执行命令时建立
+func NewRconClient(url, password string, idleTimeout time.Duration) *RconClient {
+	if idleTimeout <= 0 {
+		idleTimeout = defaultRconIdleTimeout
+	}
+	r := &RconClient{
+		url:         url,
+		password:    password,
+		idleTimeout: idleTimeout,
+		stop:        make(chan struct{}),
+	}
+	go r.healthCheck()
+	return r
+}
+
+func getRconIdleTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv(RconIdleTimeoutEnv))
+	if err != nil || seconds <= 0 {
+		return defaultRconIdleTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Execute 执行一条 RCON 命令
+// 复用的连接可能已被服务端关闭，只有命令没有写出去时才重连后重试一次，
+// 写出后读取失败的命令可能已经执行，重试会导致 changelevel、kick 等命令执行两次
+func (r *RconClient) Execute(cmd string) (string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	reused := r.conn != nil
+	if err := r.ensureConn(); err != nil {
+		return "", err
+	}
+
+	res, err := r.conn.Execute(cmd)
+	if err != nil && reused && isRconWriteError(err) {
+		r.closeConn()
+		if err := r.ensureConn(); err != nil {
+			return "", err
+		}
+		res, err = r.conn.Execute(cmd)
+	}
+	if err != nil {
+		r.closeConn()
+		return "", err
+	}
+
+	r.lastUsed = time.Now()
+	return res, nil
+}
+
+// isRconWriteError 判断错误是否发生在发送命令时，此时服务端没有收到完整的命令
+func isRconWriteError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "write"
+}
+
+// Reset 关闭当前连接，下次执行命令时重新建立
+func (r *RconClient) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.closeConn()
+}
+
+// Close 关闭连接并停止后台健康检查
+func (r *RconClient) Close() {
+	r.stopOnce.Do(func() { close(r.stop) })
+	r.Reset()
+}
+
+func (r *RconClient) ensureConn() error {
+	if r.conn != nil {
+		return nil
+	}
+
+	if wait := time.Until(r.nextDialAt); wait > 0 {
+		return fmt.Errorf("RCON连接失败: %v (%d秒后重试)", r.lastErr, int(wait.Seconds())+1)
+	}
+
+	conn, err := rcon.Dial(r.url, r.password,
+		rcon.SetDialTimeout(rconDialTimeout),
+		rcon.SetDeadline(rconCommandDeadline),
+	)
+	if err != nil {
+		r.failures++
+		r.lastErr = err
+		r.nextDialAt = time.Now().Add(rconBackoff(r.failures))
+		return fmt.Errorf("RCON连接失败: %v", err)
+	}
+
+	r.conn = conn
+	r.failures = 0
+	r.lastErr = nil
+	r.nextDialAt = time.Time{}
+	r.lastUsed = time.Now()
+	return nil
+}
+
+func (r *RconClient) closeConn() {
+	if r.conn != nil {
+		r.conn.Close()
+		r.conn = nil
+	}
+}
+
+// rconBackoff 第一次失败立即允许重试，之后按 1s, 2s, 4s... 退避，最长1分钟
+func rconBackoff(failures int) time.Duration {
+	if failures <= 1 {
+		return 0
+	}
+	backoff := time.Second << (failures - 2)
+	if backoff <= 0 || backoff > rconMaxBackoff {
+		return rconMaxBackoff
+	}
+	return backoff
+}
+
+// healthCheck 定期探测连接是否可用，并关闭空闲超时的连接
+func (r *RconClient) healthCheck() {
+	ticker := time.NewTicker(rconHealthCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			r.mu.Lock()
+			if r.conn != nil {
+				if time.Since(r.lastUsed) > r.idleTimeout {
+					r.closeConn()
+				} else if _, err := r.conn.Execute("echo"); err != nil {
+					r.closeConn()
+				}
+			}
+			r.mu.Unlock()
+		}
+	}
+}
+
+// MaskRconCommand 逐条检查用分号或换行分隔的命令，敏感命令的参数替换为掩码
+func MaskRconCommand(value string) string {
+	commands := strings.FieldsFunc(value, func(r rune) bool { return r == ';' || r == '\n' || r == '\r' })
+	masked := make([]string, 0, len(commands))
+	for _, command := range commands {
+		command = strings.TrimSpace(command)
+		fields := strings.Fields(command)
+		if len(fields) > 1 && isSensitiveRconCommand(fields[0]) {
+			command = fields[0] + " ******"
+		}
+		masked = append(masked, command)
+	}
+	return strings.Join(masked, "; ")
+}
+
+func isSensitiveRconCommand(name string) bool {
+	name = strings.ToLower(strings.Trim(name, `"`))
+	if strings.Contains(name, "password") {
+		return true
+	}
+	for _, command := range sensitiveRconCommands {
+		if name == command {
+			return true
+		}
+	}
+	return false
+}
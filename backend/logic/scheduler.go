@@ -0,0 +1,521 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	SchedulesFilePath    = "schedules.json"
+	ScheduleRunsFilePath = "schedule_runs.json"
+
+	JobTypeRcon          = "rcon"
+	JobTypeRestart       = "restart"
+	JobTypePluginEnable  = "plugin_enable"
+	JobTypePluginDisable = "plugin_disable"
+	JobTypeChangeMap     = "change_map"
+	JobTypeServerInfo    = "server_info"
+
+	JobTriggerSchedule = "schedule"
+	JobTriggerManual   = "manual"
+
+	// SchedulerActor 定时执行的任务在审计日志中的操作者
+	SchedulerActor = "scheduler"
+
+	scheduleMaxRuns   = 500 // 保留的执行记录条数
+	scheduleMaxOutput = 4096
+	defaultRunsLimit  = 50
+	scheduleRunWait   = 30 * time.Second // 手动执行时等待结果的最长时间
+)
+
+// ScheduledJob 定时任务，按 Type 使用不同的参数字段
+type ScheduledJob struct {
+	ID       string `json:"id"`
+	ServerID string `json:"server_id"`
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	Schedule string `json:"schedule"` // cron 表达式，见 ParseCron
+	Enabled  bool   `json:"enabled"`
+
+	Command     string `json:"command,omitempty"`      // rcon
+	RestartMode string `json:"restart_mode,omitempty"` // restart，见 RestartModeGraceful 等，为空时立即重启
+	Countdown   int    `json:"countdown,omitempty"`    // restart，graceful 模式的倒计时秒数
+	Plugin      string `json:"plugin,omitempty"`       // plugin_enable / plugin_disable
+	Map         string `json:"map,omitempty"`          // change_map
+	Hostname    string `json:"hostname,omitempty"`     // server_info，留空的字段保持不变
+	Motd        string `json:"motd,omitempty"`
+	Host        string `json:"host,omitempty"`
+
+	CreatedBy string     `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	LastRun   *JobRun    `json:"last_run,omitempty"`
+	NextRun   *time.Time `json:"next_run,omitempty"`
+}
+
+// jobPermissions 创建或修改各类型的任务还需要直接执行相同操作的权限
+var jobPermissions = map[string]string{
+	JobTypeRcon:          PermRconRaw,
+	JobTypeRestart:       PermServerRestart,
+	JobTypePluginEnable:  PermPluginsEnable,
+	JobTypePluginDisable: PermPluginsEnable,
+	JobTypeChangeMap:     PermMapsChange,
+	JobTypeServerInfo:    PermServerInfoEdit,
+}
+
+// JobPermission 返回创建或修改该类型任务需要的权限，未知类型返回空
+func JobPermission(jobType string) string {
+	return jobPermissions[jobType]
+}
+
+// JobRun 任务的一次执行记录
+type JobRun struct {
+	JobID       string    `json:"job_id"`
+	JobName     string    `json:"job_name"`
+	ServerID    string    `json:"server_id"`
+	Type        string    `json:"type"`
+	Trigger     string    `json:"trigger"`
+	TriggeredBy string    `json:"triggered_by"`
+	Start       time.Time `json:"start"`
+	End         time.Time `json:"end"`
+	Success     bool      `json:"success"`
+	Output      string    `json:"output,omitempty"`
+	Error       string    `json:"error,omitempty"`
+}
+
+var (
+	scheduledJobs  []*ScheduledJob
+	scheduleRuns   []JobRun
+	runningJobs    = make(map[string]bool)
+	schedulesMutex sync.Mutex
+)
+
+func init() {
+	LoadSchedules()
+}
+
+// LoadSchedules 读取定时任务和执行记录
+func LoadSchedules() {
+	schedulesMutex.Lock()
+	defer schedulesMutex.Unlock()
+
+	scheduledJobs = nil
+	scheduleRuns = nil
+	if data, err := os.ReadFile(SchedulesFilePath); err == nil {
+		if err := json.Unmarshal(data, &scheduledJobs); err != nil {
+			log.Printf("读取定时任务失败: %v", err)
+		}
+	}
+	if data, err := os.ReadFile(ScheduleRunsFilePath); err == nil {
+		if err := json.Unmarshal(data, &scheduleRuns); err != nil {
+			log.Printf("读取定时任务执行记录失败: %v", err)
+		}
+	}
+}
+
+func saveSchedules() error {
+	data, err := json.MarshalIndent(scheduledJobs, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(SchedulesFilePath, data, 0600)
+}
+
+func saveScheduleRuns() error {
+	data, err := json.Marshal(scheduleRuns)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(ScheduleRunsFilePath, data, 0600)
+}
+
+// StartScheduler 每分钟检查一次到期的任务
+func StartScheduler() {
+	go func() {
+		for {
+			now := time.Now()
+			next := now.Truncate(time.Minute).Add(time.Minute)
+			time.Sleep(next.Sub(now))
+			runDueJobs(next)
+		}
+	}()
+}
+
+func runDueJobs(now time.Time) {
+	schedulesMutex.Lock()
+	var due []ScheduledJob
+	for _, job := range scheduledJobs {
+		if !job.Enabled {
+			continue
+		}
+		schedule, err := ParseCron(job.Schedule)
+		if err != nil || !schedule.Match(now) {
+			continue
+		}
+		due = append(due, *job)
+	}
+	schedulesMutex.Unlock()
+
+	for _, job := range due {
+		go runJob(job, JobTriggerSchedule, SchedulerActor)
+	}
+}
+
+// runJob 执行任务并记录结果，同一任务上一次还没执行完时跳过
+func runJob(job ScheduledJob, trigger, by string) JobRun {
+	run := JobRun{
+		JobID:       job.ID,
+		JobName:     job.Name,
+		ServerID:    job.ServerID,
+		Type:        job.Type,
+		Trigger:     trigger,
+		TriggeredBy: by,
+		Start:       time.Now(),
+	}
+
+	schedulesMutex.Lock()
+	if runningJobs[job.ID] {
+		schedulesMutex.Unlock()
+		run.End = run.Start
+		run.Error = "上一次执行尚未结束"
+		recordJobRun(run)
+		return run
+	}
+	runningJobs[job.ID] = true
+	schedulesMutex.Unlock()
+
+	output, err := executeJob(job)
+	run.End = time.Now()
+	run.Success = err == nil
+	if len(output) > scheduleMaxOutput {
+		output = output[:scheduleMaxOutput] + "..."
+	}
+	run.Output = output
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	schedulesMutex.Lock()
+	delete(runningJobs, job.ID)
+	schedulesMutex.Unlock()
+	recordJobRun(run)
+
+	// 手动执行已由接口的审计中间件记录
+	if trigger != JobTriggerSchedule {
+		return run
+	}
+	result := "success"
+	if !run.Success {
+		result = "failed"
+	}
+	RecordAudit(AuditEntry{
+		Actor:    by,
+		Action:   "schedule.run." + job.Type,
+		ServerID: job.ServerID,
+		Target:   job.Name,
+		Params:   map[string]string{"id": job.ID, "trigger": trigger},
+		Result:   result,
+		Message:  run.Error,
+	})
+	return run
+}
+
+func recordJobRun(run JobRun) {
+	schedulesMutex.Lock()
+	defer schedulesMutex.Unlock()
+
+	scheduleRuns = append(scheduleRuns, run)
+	if len(scheduleRuns) > scheduleMaxRuns {
+		scheduleRuns = scheduleRuns[len(scheduleRuns)-scheduleMaxRuns:]
+	}
+	for _, job := range scheduledJobs {
+		if job.ID == run.JobID {
+			r := run
+			job.LastRun = &r
+			if err := saveSchedules(); err != nil {
+				log.Printf("保存定时任务失败: %v", err)
+			}
+			break
+		}
+	}
+	if err := saveScheduleRuns(); err != nil {
+		log.Printf("保存定时任务执行记录失败: %v", err)
+	}
+}
+
+func executeJob(job ScheduledJob) (string, error) {
+	s, err := GetServer(job.ServerID)
+	if err != nil {
+		return "", err
+	}
+
+	switch job.Type {
+	case JobTypeRcon:
+		client, err := s.Rcon()
+		if err != nil {
+			return "", err
+		}
+		hub := GetConsoleHub(s)
+		hub.Publish(ConsoleSourceRcon, "] "+MaskRconCommand(job.Command))
+		res, err := client.Execute(job.Command)
+		if err != nil {
+			return "", fmt.Errorf("RCON命令执行失败: %v", err)
+		}
+		if res != "" {
+			hub.Publish(ConsoleSourceRcon, res)
+		}
+		return res, nil
+
+	case JobTypeRestart:
+		pending, err := ScheduleRestart(s, job.RestartMode, time.Duration(job.Countdown)*time.Second, "", SchedulerActor)
+		if err != nil {
+			return "", err
+		}
+		if pending != nil {
+			return "已开始等待重启，结果见审计日志", nil
+		}
+		return "重启命令已执行", nil
+
+	case JobTypePluginEnable, JobTypePluginDisable:
+		enable := job.Type == JobTypePluginEnable
+		// 已经是目标状态时视为成功，避免每次执行都报错
+		if plugins, err := GetPlugins(s); err == nil {
+			for _, p := range plugins {
+				if p.Name == job.Plugin && (p.Status == "enabled") == enable {
+					return "插件已是目标状态", nil
+				}
+			}
+		}
+		if enable {
+			err = EnablePlugin(s, job.Plugin)
+		} else {
+			err = DisablePlugin(s, job.Plugin)
+		}
+		if err != nil {
+			return "", err
+		}
+		return "插件状态已修改，重启或换图后生效", nil
+
+	case JobTypeChangeMap:
+		// 地图可能在添加任务后被删除
+		if err := ValidateMapName(s, job.Map); err != nil {
+			return "", err
+		}
+		client, err := s.Rcon()
+		if err != nil {
+			return "", err
+		}
+		if _, err := client.Execute("changelevel " + job.Map); err != nil {
+			return "", fmt.Errorf("RCON命令执行失败: %v", err)
+		}
+		RequestStatusRefresh(s)
+		return "已切换到 " + job.Map, nil
+
+	case JobTypeServerInfo:
+		info := GetServerInfo(s)
+		if job.Hostname != "" {
+			info.Hostname = job.Hostname
+		}
+		if job.Motd != "" {
+			info.Motd = job.Motd
+		}
+		if job.Host != "" {
+			info.Host = job.Host
+		}
+		if err := UpdateServerInfo(s, info.Hostname, info.Motd, info.Host); err != nil {
+			return "", err
+		}
+		return "服务器信息已更新", nil
+	}
+	return "", fmt.Errorf("未知的任务类型: %s", job.Type)
+}
+
+func validateJob(job *ScheduledJob) error {
+	job.Name = strings.TrimSpace(job.Name)
+	job.Schedule = strings.TrimSpace(job.Schedule)
+	if job.Name == "" {
+		return fmt.Errorf("任务名称不能为空")
+	}
+	if _, err := ParseCron(job.Schedule); err != nil {
+		return err
+	}
+	if _, err := GetServer(job.ServerID); err != nil {
+		return err
+	}
+
+	switch job.Type {
+	case JobTypeRcon:
+		job.Command = strings.TrimSpace(job.Command)
+		if job.Command == "" {
+			return fmt.Errorf("RCON命令不能为空")
+		}
+	case JobTypeRestart:
+		if job.RestartMode != RestartModeNow && job.RestartMode != RestartModeGraceful && job.RestartMode != RestartModeWhenEmpty {
+			return fmt.Errorf("未知的重启模式: %s", job.RestartMode)
+		}
+	case JobTypePluginEnable, JobTypePluginDisable:
+		if job.Plugin == "" {
+			return fmt.Errorf("插件名称不能为空")
+		}
+	case JobTypeChangeMap:
+		job.Map = strings.TrimSpace(job.Map)
+		if job.Map == "" || unsafeRconArg(job.Map) {
+			return fmt.Errorf("无效的地图名称")
+		}
+	case JobTypeServerInfo:
+		if job.Hostname == "" && job.Motd == "" && job.Host == "" {
+			return fmt.Errorf("服务器名、公告和标题至少填写一项")
+		}
+	default:
+		return fmt.Errorf("未知的任务类型: %s", job.Type)
+	}
+	return nil
+}
+
+func findJob(id string) (*ScheduledJob, int) {
+	for i, job := range scheduledJobs {
+		if job.ID == id {
+			return job, i
+		}
+	}
+	return nil, -1
+}
+
+// GetJob 查找服务器的定时任务，返回副本
+func GetJob(serverID, id string) (*ScheduledJob, error) {
+	schedulesMutex.Lock()
+	defer schedulesMutex.Unlock()
+
+	job, _ := findJob(id)
+	if job == nil || job.ServerID != serverID {
+		return nil, fmt.Errorf("任务不存在")
+	}
+	copied := *job
+	return &copied, nil
+}
+
+// ListJobs 列出服务器的定时任务，并计算下次执行时间
+func ListJobs(serverID string) []ScheduledJob {
+	schedulesMutex.Lock()
+	defer schedulesMutex.Unlock()
+
+	now := time.Now()
+	list := make([]ScheduledJob, 0)
+	for _, job := range scheduledJobs {
+		if job.ServerID != serverID {
+			continue
+		}
+		j := *job
+		if schedule, err := ParseCron(j.Schedule); err == nil && j.Enabled {
+			if next := schedule.Next(now); !next.IsZero() {
+				j.NextRun = &next
+			}
+		}
+		list = append(list, j)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// AddJob 添加定时任务
+func AddJob(job ScheduledJob, by string) (*ScheduledJob, error) {
+	if err := validateJob(&job); err != nil {
+		return nil, err
+	}
+	id, err := newRandomID()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	job.ID = id
+	job.CreatedBy = by
+	job.CreatedAt = now
+	job.UpdatedAt = now
+	job.LastRun = nil
+	job.NextRun = nil
+
+	schedulesMutex.Lock()
+	defer schedulesMutex.Unlock()
+	scheduledJobs = append(scheduledJobs, &job)
+	return &job, saveSchedules()
+}
+
+// UpdateJob 修改定时任务，不能改变所属服务器
+func UpdateJob(job ScheduledJob) error {
+	schedulesMutex.Lock()
+	defer schedulesMutex.Unlock()
+
+	existing, _ := findJob(job.ID)
+	if existing == nil || existing.ServerID != job.ServerID {
+		return fmt.Errorf("任务不存在")
+	}
+	if err := validateJob(&job); err != nil {
+		return err
+	}
+	job.CreatedBy = existing.CreatedBy
+	job.CreatedAt = existing.CreatedAt
+	job.UpdatedAt = time.Now()
+	job.LastRun = existing.LastRun
+	job.NextRun = nil
+	*existing = job
+	return saveSchedules()
+}
+
+// DeleteJob 删除定时任务，执行记录保留
+func DeleteJob(serverID, id string) error {
+	schedulesMutex.Lock()
+	defer schedulesMutex.Unlock()
+
+	job, i := findJob(id)
+	if job == nil || job.ServerID != serverID {
+		return fmt.Errorf("任务不存在")
+	}
+	scheduledJobs = append(scheduledJobs[:i], scheduledJobs[i+1:]...)
+	return saveSchedules()
+}
+
+// RunJobNow 立即执行一次任务，等待执行完成后返回结果
+func RunJobNow(serverID, id, by string) (JobRun, error) {
+	schedulesMutex.Lock()
+	job, _ := findJob(id)
+	if job == nil || job.ServerID != serverID {
+		schedulesMutex.Unlock()
+		return JobRun{}, fmt.Errorf("任务不存在")
+	}
+	copied := *job
+	schedulesMutex.Unlock()
+
+	done := make(chan JobRun, 1)
+	go func() { done <- runJob(copied, JobTriggerManual, by) }()
+	select {
+	case run := <-done:
+		return run, nil
+	case <-time.After(scheduleRunWait):
+		return JobRun{}, fmt.Errorf("任务仍在执行，请稍后查看执行记录")
+	}
+}
+
+// ListJobRuns 列出服务器的执行记录，最近的在前，jobID 为空时列出所有任务
+func ListJobRuns(serverID, jobID string, limit int) []JobRun {
+	schedulesMutex.Lock()
+	defer schedulesMutex.Unlock()
+
+	if limit <= 0 {
+		limit = defaultRunsLimit
+	}
+	list := make([]JobRun, 0)
+	for i := len(scheduleRuns) - 1; i >= 0 && len(list) < limit; i-- {
+		run := scheduleRuns[i]
+		if run.ServerID != serverID || (jobID != "" && run.JobID != jobID) {
+			continue
+		}
+		list = append(list, run)
+	}
+	return list
+}
@@ -0,0 +1,315 @@
+package logic
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// server.cfg 以及 start.sh 按 tickrate 复制的 server.cfg.30tick 等模板
+var serverCfgNameRegex = regexp.MustCompile(`^server\.cfg(\.\d+tick)?$`)
+
+var cvarNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_]+$`)
+
+// 出现在 cfg 中但不是 cvar 的命令，不做运行时比较
+var cfgCommands = map[string]bool{
+	"exec": true, "alias": true, "echo": true, "log": true, "bind": true,
+	"sm_cvar": true, "logaddress_add": true, "banid": true, "addip": true,
+	"writeid": true, "writeip": true,
+}
+
+// ServerCfgCvar server.cfg 中的一个 cvar
+type ServerCfgCvar struct {
+	CvarConfig
+	Line    int    `json:"line"`
+	Command bool   `json:"command"`           // exec、alias 等命令
+	Runtime string `json:"runtime,omitempty"` // 通过 RCON 查询到的当前值
+	// Overridden 运行时的值与文件不一致，通常是被插件或其他 cfg 修改了
+	Overridden bool `json:"overridden"`
+}
+
+// ServerCfgFile 解析后的 server.cfg
+type ServerCfgFile struct {
+	FileName       string          `json:"file_name"`
+	Cvars          []ServerCfgCvar `json:"cvars"`
+	RuntimeChecked bool            `json:"runtime_checked"`
+	RuntimeError   string          `json:"runtime_error,omitempty"`
+}
+
+// ServerCfgUpdate 对 server.cfg 的修改，Apply 为 true 时同时通过 RCON 设置到运行中的服务器
+type ServerCfgUpdate struct {
+	FileName string            `json:"file_name"`
+	Set      map[string]string `json:"set"`
+	Remove   []string          `json:"remove"`
+	Apply    bool              `json:"apply"`
+}
+
+// ServerCfgApplyResult 通过 RCON 应用修改的结果
+type ServerCfgApplyResult struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Error string `json:"error,omitempty"`
+}
+
+func serverCfgPath(s *GameServer, fileName string) (string, error) {
+	if fileName == "" {
+		fileName = "server.cfg"
+	}
+	if !serverCfgNameRegex.MatchString(fileName) {
+		return "", fmt.Errorf("不支持的配置文件: %s", fileName)
+	}
+	return filepath.Join(s.GamePath, "cfg", fileName), nil
+}
+
+// ListServerCfgFiles 列出游戏目录中存在的 server.cfg 及 tick 模板
+func ListServerCfgFiles(s *GameServer) ([]string, error) {
+	entries, err := os.ReadDir(filepath.Join(s.GamePath, "cfg"))
+	if err != nil {
+		return nil, err
+	}
+	files := []string{}
+	for _, e := range entries {
+		if !e.IsDir() && serverCfgNameRegex.MatchString(e.Name()) {
+			files = append(files, e.Name())
+		}
+	}
+	return files, nil
+}
+
+// splitInlineComment 拆分行尾的 // 注释，忽略引号中的 //
+func splitInlineComment(line string) (string, string) {
+	inQuote := false
+	for i := 0; i < len(line); i++ {
+		switch {
+		case line[i] == '"':
+			inQuote = !inQuote
+		case !inQuote && strings.HasPrefix(line[i:], "//"):
+			return strings.TrimRight(line[:i], " \t"), line[i:]
+		}
+	}
+	return line, ""
+}
+
+// readCfgLines 按行读取 cfg，同时返回文件使用的换行符，写回时保持一致
+func readCfgLines(path string) ([]string, string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	newline := "\n"
+	if strings.Contains(string(content), "\r\n") {
+		newline = "\r\n"
+	}
+	text := strings.ReplaceAll(string(content), "\r\n", "\n")
+	return strings.Split(text, "\n"), newline, nil
+}
+
+func parseServerCfg(lines []string) []ServerCfgCvar {
+	// 去掉行尾注释后再交给通用的 cvar 解析，整行注释保持不变以便提取说明
+	stripped := make([]string, len(lines))
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "//") {
+			stripped[i] = line
+			continue
+		}
+		stripped[i], _ = splitInlineComment(line)
+	}
+
+	cvars := []ServerCfgCvar{}
+	scanCvarLines(stripped, func(index int, config CvarConfig) {
+		config.Value = strings.TrimSpace(config.Value)
+		cvars = append(cvars, ServerCfgCvar{
+			CvarConfig: config,
+			Line:       index + 1,
+			Command:    cfgCommands[strings.ToLower(config.Name)],
+		})
+	})
+	return cvars
+}
+
+// GetServerCfg 读取并解析 server.cfg，checkRuntime 为 true 时通过 RCON 查询每个 cvar 的当前值
+func GetServerCfg(s *GameServer, fileName string, checkRuntime bool) (*ServerCfgFile, error) {
+	path, err := serverCfgPath(s, fileName)
+	if err != nil {
+		return nil, err
+	}
+	lines, _, err := readCfgLines(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %v", filepath.Base(path), err)
+	}
+
+	result := &ServerCfgFile{
+		FileName: filepath.Base(path),
+		Cvars:    parseServerCfg(lines),
+	}
+	if !checkRuntime {
+		return result, nil
+	}
+
+	client, err := s.Rcon()
+	if err != nil {
+		result.RuntimeError = err.Error()
+		return result, nil
+	}
+	result.RuntimeChecked = true
+	for i := range result.Cvars {
+		cvar := &result.Cvars[i]
+		if cvar.Command {
+			continue
+		}
+		info, err := queryCvarInfo(client, cvar.Name)
+		if err != nil {
+			continue
+		}
+		cvar.Runtime = info.Value
+		cvar.Overridden = !cvarValueEqual(info.Value, cvar.Value)
+	}
+	return result, nil
+}
+
+// cvarValueEqual 数值按数值比较，避免 "1" 与 "1.0" 被认为不同
+func cvarValueEqual(a, b string) bool {
+	a, b = strings.TrimSpace(a), strings.TrimSpace(b)
+	if a == b {
+		return true
+	}
+	fa, errA := strconv.ParseFloat(a, 64)
+	fb, errB := strconv.ParseFloat(b, 64)
+	return errA == nil && errB == nil && fa == fb
+}
+
+func validateCvar(name, value string) error {
+	if !cvarNameRegex.MatchString(name) {
+		return fmt.Errorf("无效的 cvar 名称: %s", name)
+	}
+	if strings.ContainsAny(value, "\"\r\n") {
+		return fmt.Errorf("cvar %s 的值不能包含引号或换行", name)
+	}
+	return nil
+}
+
+// UpdateServerCfg 修改、新增和删除 cvar，保留注释、缩进、行尾注释和无法识别的行
+// 新增的 cvar 追加到文件末尾
+func UpdateServerCfg(s *GameServer, update ServerCfgUpdate) ([]ServerCfgApplyResult, error) {
+	path, err := serverCfgPath(s, update.FileName)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range update.Set {
+		if err := validateCvar(name, value); err != nil {
+			return nil, err
+		}
+		if cfgCommands[strings.ToLower(name)] {
+			return nil, fmt.Errorf("%s 是命令，不能作为 cvar 修改", name)
+		}
+		if protectedCvars[strings.ToLower(name)] {
+			return nil, fmt.Errorf("%s 不能通过此接口修改", name)
+		}
+	}
+	remove := make(map[string]bool)
+	for _, name := range update.Remove {
+		if protectedCvars[strings.ToLower(name)] {
+			return nil, fmt.Errorf("%s 不能通过此接口删除", name)
+		}
+		remove[strings.ToLower(name)] = true
+	}
+	set := make(map[string]string)
+	for name, value := range update.Set {
+		if remove[strings.ToLower(name)] {
+			return nil, fmt.Errorf("cvar %s 不能同时修改和删除", name)
+		}
+		set[strings.ToLower(name)] = value
+	}
+
+	lines, newline, err := readCfgLines(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取 %s 失败: %v", filepath.Base(path), err)
+	}
+	cvarLines := make(map[int]ServerCfgCvar)
+	for _, cvar := range parseServerCfg(lines) {
+		cvarLines[cvar.Line-1] = cvar
+	}
+
+	written := make(map[string]bool)
+	newLines := make([]string, 0, len(lines)+len(set))
+	for i, line := range lines {
+		cvar, ok := cvarLines[i]
+		if !ok || cvar.Command {
+			newLines = append(newLines, line)
+			continue
+		}
+		key := strings.ToLower(cvar.Name)
+		if remove[key] {
+			continue
+		}
+		value, ok := set[key]
+		if !ok {
+			newLines = append(newLines, line)
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		_, comment := splitInlineComment(line)
+		newLine := fmt.Sprintf(`%s%s "%s"`, indent, cvar.Name, value)
+		if comment != "" {
+			newLine += " " + comment
+		}
+		newLines = append(newLines, newLine)
+		written[key] = true
+	}
+
+	// 文件末尾的空行留在最后
+	trailing := 0
+	for trailing < len(newLines) && strings.TrimSpace(newLines[len(newLines)-1-trailing]) == "" {
+		trailing++
+	}
+	tail := append([]string{}, newLines[len(newLines)-trailing:]...)
+	newLines = newLines[:len(newLines)-trailing]
+	var added []string
+	for name := range update.Set {
+		if !written[strings.ToLower(name)] {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	for _, name := range added {
+		newLines = append(newLines, fmt.Sprintf(`%s "%s"`, name, update.Set[name]))
+	}
+	newLines = append(newLines, tail...)
+
+	if err := os.WriteFile(path, []byte(strings.Join(newLines, newline)), 0644); err != nil {
+		return nil, err
+	}
+
+	if !update.Apply || len(update.Set) == 0 {
+		return nil, nil
+	}
+	return applyCvars(s, update.Set), nil
+}
+
+// applyCvars 通过 RCON 设置 cvar，单个失败不影响其他 cvar
+// 名称需要先在 cvarlist 中找到，避免把命令当作 cvar 执行
+func applyCvars(s *GameServer, values map[string]string) []ServerCfgApplyResult {
+	results := make([]ServerCfgApplyResult, 0, len(values))
+	client, err := s.Rcon()
+	for name, value := range values {
+		result := ServerCfgApplyResult{Name: name, Value: value}
+		if err != nil {
+			result.Error = err.Error()
+		} else if protectedCvars[strings.ToLower(name)] {
+			result.Error = name + " 不能通过此接口修改"
+		} else if resolved, resolveErr := resolveCvarName(client, name); resolveErr != nil {
+			result.Error = resolveErr.Error()
+		} else if res, execErr := client.Execute(fmt.Sprintf(`%s "%s"`, resolved, value)); execErr != nil {
+			result.Error = execErr.Error()
+		} else if strings.Contains(res, "Unknown command") {
+			result.Error = strings.TrimSpace(res)
+		}
+		results = append(results, result)
+	}
+	RequestStatusRefresh(s)
+	return results
+}
@@ -0,0 +1,117 @@
+package logic
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+
+	"github.com/axgle/mahonia"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// ServerInfo 服务器中文名、公告 (motd.txt) 和标题 (host.txt)
+type ServerInfo struct {
+	Hostname      string `json:"hostname"`
+	HostnameError string `json:"hostname_error"`
+	Motd          string `json:"motd"`
+	Host          string `json:"host"`
+}
+
+func (s *GameServer) hostnamePath() string {
+	return filepath.Join(s.GamePath, "addons", "sourcemod", "configs", "l4d2_hostname.txt")
+}
+
+func readFileContent(path string) (string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return decodeText(content), nil
+}
+
+// decodeText 按 BOM 和内容识别 UTF-8、UTF-16 和 GBK，统一转换为 UTF-8
+func decodeText(content []byte) string {
+	switch {
+	case bytes.HasPrefix(content, []byte{0xEF, 0xBB, 0xBF}):
+		return string(content[3:])
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}):
+		return decodeUTF16(content, unicode.LittleEndian)
+	case bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		return decodeUTF16(content, unicode.BigEndian)
+	}
+
+	// 没有 BOM 的 UTF-16，ASCII 字符的高位字节为 0
+	if len(content) >= 4 && len(content)%2 == 0 {
+		if content[1] == 0 && content[3] == 0 {
+			return decodeUTF16(content, unicode.LittleEndian)
+		}
+		if content[0] == 0 && content[2] == 0 {
+			return decodeUTF16(content, unicode.BigEndian)
+		}
+	}
+
+	// Detect encoding
+	if utf8.Valid(content) {
+		return string(content)
+	}
+
+	// Try GBK
+	decoder := mahonia.NewDecoder("gbk")
+	return decoder.ConvertString(string(content))
+}
+
+func decodeUTF16(content []byte, endian unicode.Endianness) string {
+	// UseBOM 在有 BOM 时按 BOM 解码并去掉 BOM
+	decoded, _ := unicode.UTF16(endian, unicode.UseBOM).NewDecoder().Bytes(content)
+	return string(decoded)
+}
+
+func writeFileContent(path string, content string) error {
+	return os.WriteFile(path, []byte(content), 0644)
+}
+
+// GetServerInfo 读取服务器信息，中文名插件未启用时在 HostnameError 中说明
+func GetServerInfo(s *GameServer) ServerInfo {
+	info := ServerInfo{}
+
+	// Hostname
+	if _, err := os.Stat(s.hostnamePath()); os.IsNotExist(err) {
+		info.HostnameError = "请先在插件管理中启用服务器中文名插件"
+	} else {
+		content, err := readFileContent(s.hostnamePath())
+		if err != nil {
+			info.HostnameError = "读取文件失败: " + err.Error()
+		} else {
+			info.Hostname = content
+		}
+	}
+
+	// Motd 和 Host 不存在时返回空，保存时再创建
+	if content, err := readFileContent(filepath.Join(s.GamePath, "motd.txt")); err == nil {
+		info.Motd = content
+	}
+	if content, err := readFileContent(filepath.Join(s.GamePath, "host.txt")); err == nil {
+		info.Host = content
+	}
+	return info
+}
+
+// UpdateServerInfo 保存服务器信息，中文名文件只在插件已启用时写入
+func UpdateServerInfo(s *GameServer, hostname, motd, host string) error {
+	if _, err := os.Stat(s.hostnamePath()); err == nil {
+		if err := writeFileContent(s.hostnamePath(), hostname); err != nil {
+			return fmt.Errorf("保存服务器名失败: %v", err)
+		}
+	}
+
+	// For Motd and Host, we create them if they don't exist
+	if err := writeFileContent(filepath.Join(s.GamePath, "motd.txt"), motd); err != nil {
+		return fmt.Errorf("保存公告失败: %v", err)
+	}
+	if err := writeFileContent(filepath.Join(s.GamePath, "host.txt"), host); err != nil {
+		return fmt.Errorf("保存标题失败: %v", err)
+	}
+	return nil
+}
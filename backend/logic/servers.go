@@ -0,0 +1,354 @@
+package logic
+
+import (
+	"fmt"
+	"l4d2-manager-next/consts"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+const DefaultServerID = "default"
+
+// GameServer 描述一个受管理的游戏服务器实例
+// 默认实例由环境变量配置，其余实例保存在 manager_config.json 中
+type GameServer struct {
+	ID              string `json:"id"`
+	Name            string `json:"name"`
+	GamePath        string `json:"game_path"`
+	RconURL         string `json:"rcon_url"`
+	RconPassword    string `json:"rcon_password,omitempty"`
+	RestartCmd      string `json:"restart_cmd"`
+	RestartByRcon   bool   `json:"restart_by_rcon"`
+	PluginStorePath string `json:"plugin_store_path"`
+	// Launch 不为空且开启守护时由管理器启动游戏进程，重启也由管理器完成
+	Launch *LaunchOptions `json:"launch,omitempty"`
+}
+
+var (
+	rconClients      = make(map[string]*RconClient)
+	rconClientsMutex sync.Mutex
+)
+
+var serverIDRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,32}$`)
+
+// ServerRootsEnv 允许作为服务器目录的根目录，多个目录用系统路径分隔符分隔
+// 设置后新增服务器的游戏目录和插件库目录必须位于其中
+const ServerRootsEnv = "L4D2_SERVER_ROOTS"
+
+// defaultServer 根据环境变量构造默认服务器，调用方需持有 managerConfigMutex
+// 启动配置优先使用管理器中保存的内容
+func defaultServer() *GameServer {
+	restartCmd := os.Getenv("L4D2_RESTART_CMD")
+	if restartCmd == "" {
+		containerName := os.Getenv("L4D2_CONTAINER_NAME")
+		if containerName == "" {
+			containerName = "l4d2"
+		}
+		restartCmd = "docker restart " + containerName
+	}
+
+	server := &GameServer{
+		ID:              DefaultServerID,
+		Name:            "默认服务器",
+		GamePath:        consts.GamePath,
+		RconURL:         os.Getenv("L4D2_RCON_URL"),
+		RconPassword:    os.Getenv("L4D2_RCON_PASSWORD"),
+		RestartCmd:      restartCmd,
+		RestartByRcon:   os.Getenv("L4D2_RESTART_BY_RCON") == "true",
+		PluginStorePath: getStorePath(),
+		Launch:          defaultLaunchOptions(),
+	}
+	if managerConfig.DefaultLaunch != nil {
+		// 守护相关的选项仍由环境变量决定
+		if server.Launch == nil {
+			server.Launch = &LaunchOptions{}
+		}
+		server.Launch.LaunchProfile = *managerConfig.DefaultLaunch
+	}
+	return server
+}
+
+// ListServers 返回所有服务器，默认服务器始终排在第一位
+func ListServers() []*GameServer {
+	managerConfigMutex.RLock()
+	defer managerConfigMutex.RUnlock()
+
+	servers := []*GameServer{defaultServer()}
+	for i := range managerConfig.Servers {
+		s := managerConfig.Servers[i]
+		servers = append(servers, &s)
+	}
+	return servers
+}
+
+// GetServer 根据 ID 获取服务器，ID 为空时返回默认服务器
+func GetServer(id string) (*GameServer, error) {
+	managerConfigMutex.RLock()
+	defer managerConfigMutex.RUnlock()
+
+	if id == "" || id == DefaultServerID {
+		return defaultServer(), nil
+	}
+	for i := range managerConfig.Servers {
+		if managerConfig.Servers[i].ID == id {
+			s := managerConfig.Servers[i]
+			return &s, nil
+		}
+	}
+	return nil, fmt.Errorf("服务器 %s 不存在", id)
+}
+
+func validateServer(s *GameServer) error {
+	if !serverIDRegex.MatchString(s.ID) {
+		return fmt.Errorf("服务器ID只能包含字母、数字、下划线和连字符")
+	}
+	if s.ID == DefaultServerID {
+		return fmt.Errorf("默认服务器由环境变量配置，不能修改")
+	}
+	if s.GamePath == "" {
+		return fmt.Errorf("游戏目录不能为空")
+	}
+	gamePath, err := checkServerPath(s.GamePath, "游戏目录", false)
+	if err != nil {
+		return err
+	}
+	// 只接受 left4dead2 目录，避免把任意目录作为地图和插件的写入目标
+	if _, err := os.Stat(filepath.Join(gamePath, "gameinfo.txt")); err != nil {
+		return fmt.Errorf("游戏目录 %s 中没有 gameinfo.txt，请填写 left4dead2 目录", s.GamePath)
+	}
+	s.GamePath = gamePath
+	if s.PluginStorePath != "" {
+		storePath, err := checkServerPath(s.PluginStorePath, "插件库目录", true)
+		if err != nil {
+			return err
+		}
+		s.PluginStorePath = storePath
+	}
+	if s.Launch != nil {
+		return validateLaunchOptions(s.Launch)
+	}
+	return nil
+}
+
+// checkServerPath 检查目录是否存在，返回解析符号链接后的绝对路径
+// 设置了 L4D2_SERVER_ROOTS 时目录必须位于其中，allowStore 为 true 时也允许共享插件库下的目录
+func checkServerPath(path, what string, allowStore bool) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("%s必须是绝对路径", what)
+	}
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", fmt.Errorf("%s %s 不存在", what, path)
+	}
+	if info, err := os.Stat(resolved); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("%s %s 不存在", what, path)
+	}
+
+	var roots []string
+	if allowStore {
+		roots = append(roots, getStorePath())
+	}
+	env := os.Getenv(ServerRootsEnv)
+	if env == "" && !allowStore {
+		return resolved, nil
+	}
+	roots = append(roots, filepath.SplitList(env)...)
+	for _, root := range roots {
+		if root == "" {
+			continue
+		}
+		if r, err := filepath.EvalSymlinks(filepath.Clean(root)); err == nil {
+			root = r
+		}
+		if rel, err := filepath.Rel(root, resolved); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return resolved, nil
+		}
+	}
+	if env == "" {
+		return "", fmt.Errorf("%s必须位于插件库 %s 中", what, getStorePath())
+	}
+	return "", fmt.Errorf("%s必须位于 %s 中", what, env)
+}
+
+// AddServer 注册新的服务器
+// 重启命令和启动程序会在管理器所在主机上执行，只能在 manager_config.json 中手动配置
+func AddServer(s GameServer) error {
+	if err := validateServer(&s); err != nil {
+		return err
+	}
+	s.RestartCmd = ""
+	if s.Launch != nil {
+		s.Launch.Executable = ""
+	}
+
+	managerConfigMutex.Lock()
+	defer managerConfigMutex.Unlock()
+	for _, existing := range managerConfig.Servers {
+		if existing.ID == s.ID {
+			return fmt.Errorf("服务器 %s 已存在", s.ID)
+		}
+	}
+
+	if err := s.EnsureMapList(); err != nil {
+		return fmt.Errorf("创建maplist.txt失败: %v", err)
+	}
+
+	managerConfig.Servers = append(managerConfig.Servers, s)
+	if err := saveManagerConfig(); err != nil {
+		return err
+	}
+	invalidateLogServers()
+	return nil
+}
+
+// UpdateServer 更新服务器配置，RCON 密码留空表示保持不变，重启命令和启动程序始终保持不变
+func UpdateServer(s GameServer) error {
+	if err := validateServer(&s); err != nil {
+		return err
+	}
+
+	managerConfigMutex.Lock()
+	defer managerConfigMutex.Unlock()
+	for i := range managerConfig.Servers {
+		if managerConfig.Servers[i].ID == s.ID {
+			if s.RconPassword == "" {
+				s.RconPassword = managerConfig.Servers[i].RconPassword
+			}
+			s.RestartCmd = managerConfig.Servers[i].RestartCmd
+			if s.Launch != nil {
+				s.Launch.Executable = ""
+				if old := managerConfig.Servers[i].Launch; old != nil {
+					s.Launch.Executable = old.Executable
+				}
+			}
+			if err := s.EnsureMapList(); err != nil {
+				return fmt.Errorf("创建maplist.txt失败: %v", err)
+			}
+			managerConfig.Servers[i] = s
+			if err := saveManagerConfig(); err != nil {
+				return err
+			}
+			invalidateLogServers()
+			return nil
+		}
+	}
+	return fmt.Errorf("服务器 %s 不存在", s.ID)
+}
+
+// DeleteServer 移除服务器注册信息，不会删除游戏文件
+func DeleteServer(id string) error {
+	if id == DefaultServerID {
+		return fmt.Errorf("默认服务器不能删除")
+	}
+
+	managerConfigMutex.Lock()
+	defer managerConfigMutex.Unlock()
+	for i := range managerConfig.Servers {
+		if managerConfig.Servers[i].ID == id {
+			managerConfig.Servers = append(managerConfig.Servers[:i], managerConfig.Servers[i+1:]...)
+			closeRconClient(id)
+			if err := saveManagerConfig(); err != nil {
+				return err
+			}
+			invalidateLogServers()
+			return nil
+		}
+	}
+	return fmt.Errorf("服务器 %s 不存在", id)
+}
+
+func (s *GameServer) AddonsPath() string {
+	return filepath.Join(s.GamePath, "addons")
+}
+
+func (s *GameServer) MapListPath() string {
+	return filepath.Join(s.AddonsPath(), "maplist.txt")
+}
+
+// EnsureMapList 如果maplist.txt不存在，创建一个空的
+func (s *GameServer) EnsureMapList() error {
+	if _, err := os.Stat(s.MapListPath()); os.IsNotExist(err) {
+		if err := os.MkdirAll(s.AddonsPath(), 0755); err != nil {
+			return err
+		}
+		return os.WriteFile(s.MapListPath(), []byte(""), 0755)
+	}
+	return nil
+}
+
+// Rcon 返回该服务器的共享 RCON 客户端，配置变更后自动替换旧连接
+func (s *GameServer) Rcon() (*RconClient, error) {
+	if s.RconURL == "" {
+		return nil, fmt.Errorf("服务端未配置RCON链接")
+	}
+	if s.RconPassword == "" {
+		return nil, fmt.Errorf("服务端未配置RCON密码")
+	}
+
+	rconClientsMutex.Lock()
+	defer rconClientsMutex.Unlock()
+
+	client, ok := rconClients[s.ID]
+	if ok && client.url == s.RconURL && client.password == s.RconPassword {
+		return client, nil
+	}
+	if ok {
+		client.Close()
+	}
+	client = NewRconClient(s.RconURL, s.RconPassword, getRconIdleTimeout())
+	rconClients[s.ID] = client
+	return client, nil
+}
+
+func closeRconClient(id string) {
+	rconClientsMutex.Lock()
+	defer rconClientsMutex.Unlock()
+	if client, ok := rconClients[id]; ok {
+		client.Close()
+		delete(rconClients, id)
+	}
+}
+
+// Restart 重启服务器，优先使用守护进程，其次是 RCON 或重启命令
+func (s *GameServer) Restart() error {
+	if s.Supervised() {
+		return GetSupervisor(s).Restart()
+	}
+
+	if s.RestartByRcon {
+		client, err := s.Rcon()
+		if err != nil {
+			return err
+		}
+
+		_, err = client.Execute("_restart")
+		// 服务器重启后原连接失效，主动断开以便下次重新连接
+		client.Reset()
+		if err != nil {
+			return fmt.Errorf("RCON命令执行失败: %v", err)
+		}
+		return nil
+	}
+
+	if s.RestartCmd == "" {
+		return fmt.Errorf("未配置重启命令")
+	}
+
+	var err error
+	if runtime.GOOS == "windows" {
+		err = exec.Command("cmd.exe", "/c", s.RestartCmd).Run()
+	} else {
+		err = exec.Command("sh", "-c", s.RestartCmd).Run()
+	}
+	if err != nil {
+		log.Printf("服务器 %s 执行重启命令失败: %v", s.ID, err)
+		return fmt.Errorf("重启失败: %v", err)
+	}
+	return nil
+}
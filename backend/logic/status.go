@@ -0,0 +1,364 @@
+package logic
+
+import (
+	"fmt"
+	"l4d2-manager-next/steamid"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// StatusPlayer status 命令输出中的一名玩家，包括机器人和正在连接的玩家
+type StatusPlayer struct {
+	Name     string
+	Id       int
+	SteamId  string
+	Ip       string
+	Status   string
+	Delay    int
+	Loss     int
+	Duration string
+	LinkRate int
+	// SteamId64 由 SteamId 转换而来，机器人或无法解析时为空
+	SteamId64 string
+	IsBot     bool
+}
+
+// ServerStatus status 命令的解析结果
+type ServerStatus struct {
+	Users      []StatusPlayer
+	Players    string // 真人数/最大人数，兼容旧版前端
+	Humans     int
+	Bots       int
+	MaxPlayers int
+	Map        string
+	Hostname   string
+	Version    string
+	Secure     bool   // 是否启用了 VAC
+	Address    string // 本地监听地址
+	PublicIp   string
+	Os         string
+	ServerId   string // 服务器的 SteamID，匿名登录时为空
+	Difficulty string
+	GameMode   string
+	// Warnings 无法识别的行，解析不再静默丢弃
+	Warnings []string
+}
+
+var (
+	statusHeaderRegex   = regexp.MustCompile(`^([a-z/]+)\s*:\s*(.*)$`)
+	statusPlayersRegex  = regexp.MustCompile(`(\d+) humans?, (\d+) bots? \((\d+)(?:/\d+)? max\)`)
+	statusPublicIPRegex = regexp.MustCompile(`public(?: ip:)?\s*([0-9a-fA-F.:\[\]]+?)(?::\d+)?\s*[\])]`)
+	statusDurationRegex = regexp.MustCompile(`^\d+(?::\d+)+$`)
+	// # 125 5 "LaoYutang" STEAM_1:1:85790159  2:23:17 41 0 active 60000 61.141.153.96:52904
+	// 部分版本没有第二列的序号，机器人只有 uniqueid 和 state 两列
+	statusUserRegex = regexp.MustCompile(`^#\s*(\d+)\s+(?:\d+\s+)?"(.*)"\s+(\S+)\s*(.*)$`)
+)
+
+// ParseStatus 解析 status 命令的输出
+func ParseStatus(statusText string) *ServerStatus {
+	status := &ServerStatus{}
+	hasCount := false
+
+	for _, line := range strings.Split(statusText, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		if strings.HasPrefix(line, "#") {
+			// 表头和结束行
+			if strings.Contains(line, "userid") || strings.EqualFold(strings.TrimLeft(line, "# "), "end") {
+				continue
+			}
+			user, err := parseStatusPlayer(line)
+			if err != nil {
+				status.Warnings = append(status.Warnings, err.Error())
+				continue
+			}
+			status.Users = append(status.Users, *user)
+			continue
+		}
+
+		m := statusHeaderRegex.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		key, value := m[1], strings.TrimSpace(m[2])
+		switch key {
+		case "hostname":
+			status.Hostname = value
+		case "map":
+			// 部分版本在地图名后带有坐标: c1m1_hotel at: 0 x, 0 y, 0 z
+			status.Map, _, _ = strings.Cut(value, " ")
+		case "version":
+			fields := strings.Fields(value)
+			if len(fields) > 0 {
+				status.Version = fields[0]
+			}
+			for _, f := range fields {
+				if f == "secure" {
+					status.Secure = true
+				}
+			}
+		case "udp/ip":
+			fields := strings.Fields(value)
+			if len(fields) > 0 {
+				status.Address = fields[0]
+			}
+			if pm := statusPublicIPRegex.FindStringSubmatch(value); pm != nil {
+				status.PublicIp = strings.Trim(pm[1], "[]")
+			}
+		case "os":
+			status.Os = value
+		case "steamid":
+			fields := strings.Fields(value)
+			if len(fields) > 0 && fields[0] != "not" {
+				status.ServerId = fields[0]
+			}
+		case "players":
+			pm := statusPlayersRegex.FindStringSubmatch(value)
+			if pm == nil {
+				status.Warnings = append(status.Warnings, fmt.Sprintf("无法解析人数: %s", line))
+				continue
+			}
+			status.Humans, _ = strconv.Atoi(pm[1])
+			status.Bots, _ = strconv.Atoi(pm[2])
+			status.MaxPlayers, _ = strconv.Atoi(pm[3])
+			hasCount = true
+		}
+	}
+
+	// 没有 players 行时按玩家列表统计
+	if !hasCount {
+		for _, u := range status.Users {
+			if u.IsBot {
+				status.Bots++
+			} else {
+				status.Humans++
+			}
+		}
+	}
+	if hasCount || len(status.Users) > 0 {
+		status.Players = fmt.Sprintf("%d/%d", status.Humans, status.MaxPlayers)
+	}
+	return status
+}
+
+func parseStatusPlayer(line string) (*StatusPlayer, error) {
+	matches := statusUserRegex.FindStringSubmatch(line)
+	if matches == nil {
+		return nil, fmt.Errorf("无法解析玩家: %s", line)
+	}
+
+	userid, _ := strconv.Atoi(matches[1])
+	player := &StatusPlayer{
+		Id:      userid,
+		Name:    matches[2],
+		SteamId: matches[3],
+	}
+	rest := strings.Fields(matches[4])
+
+	if player.SteamId == "BOT" {
+		player.IsBot = true
+		if len(rest) > 0 {
+			player.Status = rest[len(rest)-1]
+		}
+		return player, nil
+	}
+
+	// 正常为 connected ping loss state rate adr，正在连接的玩家可能缺少后面几列
+	if len(rest) > 0 && statusDurationRegex.MatchString(rest[0]) {
+		player.Duration = rest[0]
+		rest = rest[1:]
+	}
+	ints := []*int{&player.Delay, &player.Loss}
+	for len(ints) > 0 && len(rest) > 0 {
+		n, err := strconv.Atoi(rest[0])
+		if err != nil {
+			break
+		}
+		*ints[0] = n
+		ints, rest = ints[1:], rest[1:]
+	}
+	if len(rest) > 0 {
+		player.Status = rest[0]
+		rest = rest[1:]
+	}
+	if len(rest) > 0 {
+		if n, err := strconv.Atoi(rest[0]); err == nil {
+			player.LinkRate = n
+			rest = rest[1:]
+		}
+	}
+	if len(rest) > 0 {
+		// IPv4:port、[IPv6]:port 或 loopback
+		player.Ip = rest[0]
+		rest = rest[1:]
+	}
+	if len(rest) > 0 || player.Status == "" {
+		return nil, fmt.Errorf("无法解析玩家: %s", line)
+	}
+
+	if id, err := steamid.Parse(player.SteamId); err == nil {
+		player.SteamId64 = id.SteamID64()
+	}
+	return player, nil
+}
+
+func parseDifficulty(difficultyText string) string {
+	// 解析z_difficulty命令的返回值
+	// 格式类似: "z_difficulty" = "Easy" ( def. "Normal" )
+	//          game replicated
+	//          - Difficulty of the current game (Easy, Normal, Hard, Impossible)
+
+	// 使用正则表达式提取难度值
+	re := regexp.MustCompile(`"z_difficulty"\s*=\s*"([^"]+)"`)
+	matches := re.FindStringSubmatch(difficultyText)
+
+	if len(matches) > 1 {
+		difficulty := matches[1]
+
+		// 转换为中文显示
+		switch strings.ToLower(difficulty) {
+		case "easy":
+			return "简单"
+		case "normal":
+			return "普通"
+		case "hard":
+			return "高级"
+		case "impossible":
+			return "专家"
+		default:
+			return difficulty
+		}
+	}
+
+	return "未知"
+}
+
+func parseGameMode(gameModeText string) string {
+	// 解析sm_cvar mp_gamemode命令的返回值
+	// 格式类似: [SM] Value of cvar "mp_gamemode": "coop"
+	// 或者: "mp_gamemode" = "coop" ( def. "coop" )
+
+	// 先尝试匹配 [SM] 格式
+	reSM := regexp.MustCompile(`\[SM\]\s*Value of cvar "mp_gamemode":\s*"([^"]+)"`)
+	matches := reSM.FindStringSubmatch(gameModeText)
+
+	if len(matches) > 1 {
+		gameMode := matches[1]
+		return translateGameMode(gameMode)
+	}
+
+	// 再尝试匹配标准格式
+	re := regexp.MustCompile(`"mp_gamemode"\s*=\s*"([^"]+)"`)
+	matches = re.FindStringSubmatch(gameModeText)
+
+	if len(matches) > 1 {
+		gameMode := matches[1]
+		return translateGameMode(gameMode)
+	}
+
+	return "未知"
+}
+
+func translateGameMode(gameMode string) string {
+	// 转换为中文显示
+	switch strings.ToLower(gameMode) {
+	case "coop":
+		return "合作"
+	case "realism":
+		return "写实"
+	case "survival":
+		return "生存"
+	case "versus":
+		return "对抗"
+	case "scavenge":
+		return "拾荒"
+	case "holdout":
+		return "坚守"
+	case "mutation1":
+		return "地球上最后一人"
+	case "mutation2":
+		return "爆头！"
+	case "mutation3":
+		return "血流不止"
+	case "mutation4":
+		return "绝境求生"
+	case "mutation5":
+		return "四剑客"
+	case "mutation7":
+		return "链锯屠杀"
+	case "mutation8":
+		return "铁人"
+	case "mutation9":
+		return "地球上最后侏儒"
+	case "mutation10":
+		return "仅容一人"
+	case "mutation11":
+		return "医疗末日"
+	case "mutation12":
+		return "写实对抗"
+	case "mutation13":
+		return "跟随公升"
+	case "mutation14":
+		return "碎尸盛宴"
+	case "mutation15":
+		return "对抗生存"
+	case "mutation16":
+		return "猎杀派对"
+	case "mutation17":
+		return "孤胆枪手"
+	case "mutation18":
+		return "失血对抗"
+	case "mutation19":
+		return "无尽坦克！"
+	case "mutation20":
+		return "治疗侏儒"
+	case "community1":
+		return "特感速递"
+	case "community2":
+		return "流感季节"
+	case "community3":
+		return "骑乘派对"
+	case "community4":
+		return "梦魇"
+	case "community5":
+		return "死亡之门"
+	case "community6":
+		return "Confogl"
+	default:
+		return gameMode
+	}
+}
+
+// FindPlayer 按 SteamID 查找在线玩家，支持任意 SteamID 格式
+func (s *ServerStatus) FindPlayer(id string) *StatusPlayer {
+	for i := range s.Users {
+		if !s.Users[i].IsBot && steamid.Equal(s.Users[i].SteamId, id) {
+			return &s.Users[i]
+		}
+	}
+	return nil
+}
+
+// FindPlayerByUserID 按用户ID查找在线玩家
+func (s *ServerStatus) FindPlayerByUserID(id int) *StatusPlayer {
+	for i := range s.Users {
+		if s.Users[i].Id == id {
+			return &s.Users[i]
+		}
+	}
+	return nil
+}
+
+// FindPlayerByName 按名称查找在线玩家，名称需要完全一致
+func (s *ServerStatus) FindPlayerByName(name string) *StatusPlayer {
+	for i := range s.Users {
+		if s.Users[i].Name == name {
+			return &s.Users[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,191 @@
+package logic
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	StatusIntervalEnv = "L4D2_STATUS_INTERVAL" // 状态轮询间隔(秒)，默认 10
+
+	defaultStatusInterval = 10 * time.Second
+)
+
+// CachedStatus 轮询得到的服务器状态
+// 轮询失败时保留上一次的结果，Stale 表示数据已过期，Error 为最近一次失败的原因
+type CachedStatus struct {
+	ServerStatus
+	Stale       bool      `json:"stale"`
+	LastUpdated time.Time `json:"last_updated"`
+	Error       string    `json:"error,omitempty"`
+}
+
+type statusEntry struct {
+	status      *ServerStatus
+	lastUpdated time.Time
+	err         error
+}
+
+var (
+	statusCache      = make(map[string]*statusEntry)
+	statusCacheMutex sync.RWMutex
+	statusRefresh    = make(chan string, 16)
+)
+
+// StartStatusPoller 定期刷新所有服务器的状态，读取方直接使用缓存
+func StartStatusPoller() {
+	interval := getStatusInterval()
+	go func() {
+		refreshAllStatus()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				refreshAllStatus()
+			case id := <-statusRefresh:
+				if s, err := GetServer(id); err == nil {
+					refreshStatus(s)
+				}
+			}
+		}
+	}()
+}
+
+// refreshAllStatus 并发查询，单个服务器无响应不会拖慢其他服务器
+func refreshAllStatus() {
+	var wg sync.WaitGroup
+	for _, s := range ListServers() {
+		wg.Add(1)
+		go func(s *GameServer) {
+			defer wg.Done()
+			refreshStatus(s)
+		}(s)
+	}
+	wg.Wait()
+}
+
+func getStatusInterval() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv(StatusIntervalEnv))
+	if err != nil || seconds <= 0 {
+		return defaultStatusInterval
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// RequestStatusRefresh 修改地图、难度等之后让轮询尽快刷新，不等待下一个周期
+func RequestStatusRefresh(s *GameServer) {
+	select {
+	case statusRefresh <- s.ID:
+	default:
+	}
+}
+
+// GetCachedStatus 返回缓存的状态，还没有轮询过的服务器会立即查询一次
+func GetCachedStatus(s *GameServer) (*CachedStatus, error) {
+	statusCacheMutex.RLock()
+	entry, ok := statusCache[s.ID]
+	statusCacheMutex.RUnlock()
+	if !ok {
+		entry = refreshStatus(s)
+	}
+
+	if entry.status == nil {
+		return nil, entry.err
+	}
+	cached := &CachedStatus{
+		ServerStatus: *entry.status,
+		LastUpdated:  entry.lastUpdated,
+		Stale:        entry.err != nil || time.Since(entry.lastUpdated) > 3*getStatusInterval(),
+	}
+	if entry.err != nil {
+		cached.Error = entry.err.Error()
+	}
+	return cached, nil
+}
+
+// refreshStatus 查询一次状态并更新缓存，与上次结果比较后发布变化事件
+func refreshStatus(s *GameServer) *statusEntry {
+	status, err := queryStatus(s)
+
+	statusCacheMutex.Lock()
+	prev := statusCache[s.ID]
+	entry := &statusEntry{status: status, lastUpdated: time.Now(), err: err}
+	if err != nil && prev != nil {
+		entry.status = prev.status
+		entry.lastUpdated = prev.lastUpdated
+	}
+	statusCache[s.ID] = entry
+	statusCacheMutex.Unlock()
+
+	if err == nil && prev != nil && prev.status != nil {
+		publishStatusChanges(s.ID, prev.status, status, entry.lastUpdated)
+	}
+	return entry
+}
+
+func queryStatus(s *GameServer) (*ServerStatus, error) {
+	client, err := s.Rcon()
+	if err != nil {
+		return nil, err
+	}
+	res, err := client.Execute("status")
+	if err != nil {
+		return nil, err
+	}
+
+	status := ParseStatus(res)
+	// 难度和模式获取失败不影响整体状态
+	difficultyRes, err := client.Execute("z_difficulty")
+	if err != nil {
+		difficultyRes = "Unknown"
+	}
+	gameModeRes, err := client.Execute("sm_cvar mp_gamemode")
+	if err != nil {
+		gameModeRes = "Unknown"
+	}
+	status.Difficulty = parseDifficulty(difficultyRes)
+	status.GameMode = parseGameMode(gameModeRes)
+	return status, nil
+}
+
+func publishStatusChanges(serverID string, prev, cur *ServerStatus, now time.Time) {
+	if prev.Map != cur.Map && cur.Map != "" {
+		PublishEvent(StatusMapChangeEvent{
+			EventBase: EventBase{Type: EventStatusMapChange, ServerID: serverID, Time: now},
+			From:      prev.Map,
+			To:        cur.Map,
+		})
+	}
+
+	// 用 userid 区分玩家，同一玩家重连后 userid 会变化，视为离开后重新加入
+	humans := func(status *ServerStatus) map[int]StatusPlayer {
+		m := make(map[int]StatusPlayer)
+		for _, u := range status.Users {
+			if !u.IsBot {
+				m[u.Id] = u
+			}
+		}
+		return m
+	}
+	// 先发布离开再发布加入，重连的玩家会先结束旧会话再开始新会话
+	before, after := humans(prev), humans(cur)
+	for id, u := range before {
+		if _, ok := after[id]; !ok {
+			PublishEvent(StatusPlayerEvent{
+				EventBase: EventBase{Type: EventStatusPlayerLeave, ServerID: serverID, Time: now},
+				Player:    u,
+			})
+		}
+	}
+	for id, u := range after {
+		if _, ok := before[id]; !ok {
+			PublishEvent(StatusPlayerEvent{
+				EventBase: EventBase{Type: EventStatusPlayerJoin, ServerID: serverID, Time: now},
+				Player:    u,
+			})
+		}
+	}
+}
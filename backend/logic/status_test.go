@@ -0,0 +1,205 @@
+package logic
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseStatusPlayer(t *testing.T) {
+	tests := []struct {
+		name    string
+		line    string
+		want    *StatusPlayer
+		wantErr bool
+	}{
+		{
+			name: "STEAM_ ID",
+			line: `# 125 5 "LaoYutang" STEAM_1:1:85790159  2:23:17 41 0 active 60000 61.141.153.96:52904`,
+			want: &StatusPlayer{
+				Name: "LaoYutang", Id: 125, SteamId: "STEAM_1:1:85790159", Ip: "61.141.153.96:52904",
+				Status: "active", Delay: 41, Loss: 0, Duration: "2:23:17", LinkRate: 60000,
+				SteamId64: "76561198131846047",
+			},
+		},
+		{
+			name: "[U:1:n] ID",
+			line: `# 3 "Player" [U:1:171580319] 05:12 60 1 active 30000 10.0.0.2:27005`,
+			want: &StatusPlayer{
+				Name: "Player", Id: 3, SteamId: "[U:1:171580319]", Ip: "10.0.0.2:27005",
+				Status: "active", Delay: 60, Loss: 1, Duration: "05:12", LinkRate: 30000,
+				SteamId64: "76561198131846047",
+			},
+		},
+		{
+			name: "没有序号列",
+			line: `#  7 "NoIndex" STEAM_1:0:2 01:00 50 0 active 20000 1.2.3.4:27005`,
+			want: &StatusPlayer{
+				Name: "NoIndex", Id: 7, SteamId: "STEAM_1:0:2", Ip: "1.2.3.4:27005",
+				Status: "active", Delay: 50, Duration: "01:00", LinkRate: 20000,
+				SteamId64: "76561197960265732",
+			},
+		},
+		{
+			name: "机器人",
+			line: `#  2 "Coach" BOT active`,
+			want: &StatusPlayer{Name: "Coach", Id: 2, SteamId: "BOT", Status: "active", IsBot: true},
+		},
+		{
+			name: "机器人带序号",
+			line: `# 4 1 "Nick" BOT spawning`,
+			want: &StatusPlayer{Name: "Nick", Id: 4, SteamId: "BOT", Status: "spawning", IsBot: true},
+		},
+		{
+			name: "IPv6 地址",
+			line: `# 8 "V6" [U:1:2] 00:42 20 0 active 30000 [2001:db8::1]:27005`,
+			want: &StatusPlayer{
+				Name: "V6", Id: 8, SteamId: "[U:1:2]", Ip: "[2001:db8::1]:27005",
+				Status: "active", Delay: 20, Duration: "00:42", LinkRate: 30000,
+				SteamId64: "76561197960265730",
+			},
+		},
+		{
+			name: "本地玩家 loopback",
+			line: `# 9 "Host" STEAM_1:0:1 00:10 0 0 active 100000 loopback`,
+			want: &StatusPlayer{
+				Name: "Host", Id: 9, SteamId: "STEAM_1:0:1", Ip: "loopback",
+				Status: "active", Duration: "00:10", LinkRate: 100000,
+				SteamId64: "76561197960265730",
+			},
+		},
+		{
+			name: "正在连接只有状态",
+			line: `# 10 "Joining" STEAM_ID_PENDING connecting`,
+			want: &StatusPlayer{Name: "Joining", Id: 10, SteamId: "STEAM_ID_PENDING", Status: "connecting"},
+		},
+		{
+			name: "正在连接缺少速率和地址",
+			line: `# 11 "Half" [U:1:2] 00:03 spawning`,
+			want: &StatusPlayer{Name: "Half", Id: 11, SteamId: "[U:1:2]", Status: "spawning", Duration: "00:03", SteamId64: "76561197960265730"},
+		},
+		{
+			name: "名称中有空格",
+			line: `# 12 "A B  C" STEAM_1:0:1 00:10 30 0 active 30000 1.2.3.4:1`,
+			want: &StatusPlayer{
+				Name: "A B  C", Id: 12, SteamId: "STEAM_1:0:1", Ip: "1.2.3.4:1",
+				Status: "active", Delay: 30, Duration: "00:10", LinkRate: 30000,
+				SteamId64: "76561197960265730",
+			},
+		},
+		{name: "缺少名称", line: `# 13 STEAM_1:0:1 active`, wantErr: true},
+		{name: "缺少状态", line: `# 14 "NoState" STEAM_1:0:1 00:10 30 0`, wantErr: true},
+		{name: "多余的列", line: `# 15 "Extra" STEAM_1:0:1 00:10 30 0 active 30000 1.2.3.4:1 junk`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseStatusPlayer(tt.line)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("期望解析失败，实际得到 %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got  %+v\nwant %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		name         string
+		text         string
+		humans       int
+		bots         int
+		maxPlayers   int
+		players      string
+		users        int
+		warnings     int
+		warningMatch string
+	}{
+		{
+			name: "players 行带 /max",
+			text: `hostname: Test Server
+version : 2.2.4.3 8749 secure
+udp/ip  : 0.0.0.0:27015 [ public 1.2.3.4:27015 ]
+os      : Linux Dedicated
+map     : c1m1_hotel at: 0 x, 0 y, 0 z
+players : 1 humans, 1 bots (8/0 max) (not hibernating)
+
+# userid name uniqueid connected ping loss state rate adr
+# 125 5 "LaoYutang" STEAM_1:1:85790159  2:23:17 41 0 active 60000 61.141.153.96:52904
+#  2 "Coach" BOT active
+#end`,
+			humans: 1, bots: 1, maxPlayers: 8, players: "1/8", users: 2,
+		},
+		{
+			name: "players 行不带 /max",
+			text: `map     : c2m1_highway
+players : 0 humans, 4 bots (4 max)
+#  2 "Coach" BOT active
+#  3 "Nick" BOT active
+#  4 "Ellis" BOT active
+#  5 "Rochelle" BOT active`,
+			humans: 0, bots: 4, maxPlayers: 4, players: "0/4", users: 4,
+		},
+		{
+			name:   "单数形式",
+			text:   `players : 1 human, 1 bot (4 max)`,
+			humans: 1, bots: 1, maxPlayers: 4, players: "1/4",
+		},
+		{
+			name: "没有 players 行时按列表统计",
+			text: `# 125 5 "LaoYutang" STEAM_1:1:85790159  2:23:17 41 0 active 60000 61.141.153.96:52904
+#  2 "Coach" BOT active`,
+			humans: 1, bots: 1, players: "1/0", users: 2,
+		},
+		{
+			name: "无法识别的玩家行",
+			text: `players : 1 humans, 0 bots (8 max)
+# 125 5 "LaoYutang" STEAM_1:1:85790159  2:23:17 41 0 active 60000 61.141.153.96:52904
+# this is not a player
+#1 broken`,
+			humans: 1, maxPlayers: 8, players: "1/8", users: 1, warnings: 2, warningMatch: "无法解析玩家",
+		},
+		{
+			name:     "无法识别的人数",
+			text:     `players : lots of people`,
+			warnings: 1, warningMatch: "无法解析人数",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := ParseStatus(tt.text)
+			if status.Humans != tt.humans || status.Bots != tt.bots || status.MaxPlayers != tt.maxPlayers {
+				t.Errorf("人数 = %d/%d/%d，期望 %d/%d/%d", status.Humans, status.Bots, status.MaxPlayers, tt.humans, tt.bots, tt.maxPlayers)
+			}
+			if status.Players != tt.players {
+				t.Errorf("Players = %q，期望 %q", status.Players, tt.players)
+			}
+			if len(status.Users) != tt.users {
+				t.Errorf("玩家数 = %d，期望 %d", len(status.Users), tt.users)
+			}
+			if len(status.Warnings) != tt.warnings {
+				t.Fatalf("Warnings = %q，期望 %d 条", status.Warnings, tt.warnings)
+			}
+			for _, w := range status.Warnings {
+				if !strings.Contains(w, tt.warningMatch) {
+					t.Errorf("Warning %q 不包含 %q", w, tt.warningMatch)
+				}
+			}
+		})
+	}
+
+	status := ParseStatus(tests[0].text)
+	if status.Hostname != "Test Server" || status.Map != "c1m1_hotel" || status.Version != "2.2.4.3" ||
+		!status.Secure || status.Address != "0.0.0.0:27015" || status.PublicIp != "1.2.3.4" {
+		t.Errorf("表头解析错误: %+v", status)
+	}
+}
@@ -0,0 +1,444 @@
+package logic
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ProcessStopped  = "stopped"
+	ProcessRunning  = "running"
+	ProcessStopping = "stopping"
+	ProcessBackoff  = "backoff" // 崩溃后等待自动重启
+
+	processOutputLines = 200
+	processStopTimeout = 15 * time.Second
+	processMinBackoff  = 5 * time.Second
+	processMaxBackoff  = 5 * time.Minute
+	// 运行超过这个时间后再崩溃，退避时间从头计算
+	processStableAfter = 10 * time.Minute
+)
+
+// LaunchOptions 由管理器直接启动并守护 srcds 时的启动参数
+type LaunchOptions struct {
+	Supervise  bool   `json:"supervise"`
+	Executable string `json:"executable"` // srcds_run 或 srcds.exe，为空时在游戏目录的上一级查找，接口中传入的值会被忽略
+	LaunchProfile
+	Exec        string `json:"exec"`         // +exec 的配置文件，默认 server.cfg
+	AutoRestart bool   `json:"auto_restart"` // 崩溃后自动重启
+}
+
+// ProcessExit 进程的一次退出
+type ProcessExit struct {
+	Time     time.Time `json:"time"`
+	Code     int       `json:"code"`
+	Error    string    `json:"error,omitempty"`
+	Crashed  bool      `json:"crashed"`
+	Uptime   int64     `json:"uptime"` // 秒
+	Restarts int       `json:"restarts"`
+}
+
+// ProcessStatus 守护进程的状态
+type ProcessStatus struct {
+	State       string       `json:"state"`
+	PID         int          `json:"pid,omitempty"`
+	Command     []string     `json:"command,omitempty"`
+	StartedAt   *time.Time   `json:"started_at,omitempty"`
+	NextRestart *time.Time   `json:"next_restart,omitempty"`
+	Crashes     int          `json:"crashes"` // 连续崩溃次数
+	LastExit    *ProcessExit `json:"last_exit,omitempty"`
+	Output      []string     `json:"output"`
+}
+
+// Supervisor 启动并守护一个服务器的 srcds 进程
+type Supervisor struct {
+	serverID string
+
+	mu          sync.Mutex
+	cmd         *exec.Cmd
+	state       string
+	command     []string
+	startedAt   time.Time
+	nextRestart time.Time
+	crashes     int
+	lastExit    *ProcessExit
+	output      []string
+	stopping    bool
+	exited      chan struct{}
+	cancelRetry chan struct{}
+}
+
+var (
+	supervisors      = make(map[string]*Supervisor)
+	supervisorsMutex sync.Mutex
+)
+
+// Supervised 是否由管理器守护游戏进程
+func (s *GameServer) Supervised() bool {
+	return s.Launch != nil && s.Launch.Supervise
+}
+
+// GetSupervisor 获取服务器的守护进程管理
+func GetSupervisor(s *GameServer) *Supervisor {
+	supervisorsMutex.Lock()
+	defer supervisorsMutex.Unlock()
+
+	sup, ok := supervisors[s.ID]
+	if !ok {
+		sup = &Supervisor{serverID: s.ID, state: ProcessStopped}
+		supervisors[s.ID] = sup
+	}
+	return sup
+}
+
+// StartSupervisors 启动所有开启了守护的服务器
+func StartSupervisors() {
+	for _, s := range ListServers() {
+		if !s.Supervised() {
+			continue
+		}
+		if err := GetSupervisor(s).Start(); err != nil {
+			log.Printf("启动服务器 %s 失败: %v", s.ID, err)
+		}
+	}
+}
+
+// defaultLaunchOptions 默认服务器的启动参数，L4D2_SUPERVISE=true 时启用
+// 端口和 tickrate 与 Docker 镜像使用相同的环境变量
+func defaultLaunchOptions() *LaunchOptions {
+	if os.Getenv("L4D2_SUPERVISE") != "true" {
+		return nil
+	}
+	port, _ := strconv.Atoi(os.Getenv("L4D2_PORT"))
+	tickrate, _ := strconv.Atoi(os.Getenv("L4D2_TICK"))
+	maxPlayers, _ := strconv.Atoi(os.Getenv("L4D2_MAXPLAYERS"))
+	setMax, _ := strconv.Atoi(os.Getenv("L4D2_SETMAX"))
+	return &LaunchOptions{
+		Supervise:  true,
+		Executable: os.Getenv("L4D2_SRCDS_PATH"),
+		LaunchProfile: LaunchProfile{
+			Port:       port,
+			Tickrate:   tickrate,
+			MaxPlayers: maxPlayers,
+			SetMax:     setMax,
+			Map:        os.Getenv("L4D2_MAP"),
+			ExtraArgs:  os.Getenv("L4D2_SRCDS_ARGS"),
+		},
+		AutoRestart: os.Getenv("L4D2_AUTO_RESTART") != "false",
+	}
+}
+
+func validateLaunchOptions(opts *LaunchOptions) error {
+	if err := validateLaunchProfile(&opts.LaunchProfile); err != nil {
+		return err
+	}
+	if strings.ContainsAny(opts.Exec, " \t\"") {
+		return fmt.Errorf("配置文件名不能包含空格或引号")
+	}
+	return nil
+}
+
+func validateLaunchProfile(opts *LaunchProfile) error {
+	if opts.Port < 0 || opts.Port > 65535 {
+		return fmt.Errorf("无效的端口: %d", opts.Port)
+	}
+	if opts.Tickrate < 0 || opts.Tickrate > 1000 {
+		return fmt.Errorf("无效的 tickrate: %d", opts.Tickrate)
+	}
+	if opts.MaxPlayers < 0 || opts.MaxPlayers > 32 {
+		return fmt.Errorf("最大人数应在 1-32 之间，0 表示不设置")
+	}
+	if opts.SetMax < 0 || opts.SetMax > 32 {
+		return fmt.Errorf("sv_setmax 应在 1-32 之间，0 表示不设置")
+	}
+	if strings.ContainsAny(opts.ExtraArgs, "\r\n") {
+		return fmt.Errorf("附加参数不能包含换行")
+	}
+	// 附加参数按空白拆分，与 start.sh 中的 read -a 一致，不支持用引号包含空格
+	if strings.ContainsAny(opts.ExtraArgs, "\"'") {
+		return fmt.Errorf("附加参数不支持引号，参数中不能包含空格")
+	}
+	if strings.ContainsAny(opts.Map, " \t\"'") {
+		return fmt.Errorf("地图名不能包含空格或引号")
+	}
+	return nil
+}
+
+// launchCommand 根据启动参数生成命令行
+func launchCommand(s *GameServer) (string, []string, error) {
+	opts := s.Launch
+	if opts == nil {
+		return "", nil, fmt.Errorf("未配置启动参数")
+	}
+
+	exe := opts.Executable
+	if exe == "" {
+		name := "srcds_run"
+		if runtime.GOOS == "windows" {
+			name = "srcds.exe"
+		}
+		exe = filepath.Join(filepath.Dir(filepath.Clean(s.GamePath)), name)
+	}
+	if _, err := os.Stat(exe); err != nil {
+		return "", nil, fmt.Errorf("找不到启动程序 %s", exe)
+	}
+
+	args := []string{"-game", "left4dead2"}
+	switch strings.ToLower(filepath.Base(exe)) {
+	case "srcds_run":
+		// 崩溃重启由管理器负责，不使用 srcds_run 自带的重启
+		args = append(args, "-norestart")
+	case "srcds.exe":
+		args = append(args, "-console")
+	}
+	if opts.Tickrate > 0 {
+		args = append(args, "-tickrate", strconv.Itoa(opts.Tickrate))
+	}
+	if opts.MaxPlayers > 0 {
+		args = append(args, "-maxplayers", strconv.Itoa(opts.MaxPlayers))
+	}
+	if opts.Port > 0 {
+		args = append(args, "+hostport", strconv.Itoa(opts.Port))
+	}
+	if opts.SetMax > 0 {
+		args = append(args, "+sv_setmax", strconv.Itoa(opts.SetMax))
+	}
+	execCfg := opts.Exec
+	if execCfg == "" {
+		execCfg = "server.cfg"
+	}
+	args = append(args, "+exec", execCfg)
+	if opts.Map != "" {
+		args = append(args, "+map", opts.Map)
+	}
+	args = append(args, strings.Fields(opts.ExtraArgs)...)
+	return exe, args, nil
+}
+
+// Start 启动进程，已在运行时返回错误
+func (sup *Supervisor) Start() error {
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+
+	if sup.state == ProcessRunning || sup.state == ProcessStopping {
+		return fmt.Errorf("服务器已在运行")
+	}
+	if sup.cancelRetry != nil {
+		close(sup.cancelRetry)
+		sup.cancelRetry = nil
+	}
+	return sup.startLocked()
+}
+
+func (sup *Supervisor) startLocked() error {
+	s, err := GetServer(sup.serverID)
+	if err != nil {
+		return err
+	}
+	exe, args, err := launchCommand(s)
+	if err != nil {
+		sup.state = ProcessStopped
+		return err
+	}
+
+	cmd := exec.Command(exe, args...)
+	cmd.Dir = filepath.Dir(exe)
+	setProcessGroup(cmd)
+	// 使用 os.Pipe 而不是 StdoutPipe，Wait 不必等输出读完，子进程残留也不会阻塞退出检测
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+	err = cmd.Start()
+	pw.Close()
+	if err != nil {
+		pr.Close()
+		sup.state = ProcessStopped
+		return fmt.Errorf("启动失败: %v", err)
+	}
+
+	sup.cmd = cmd
+	sup.state = ProcessRunning
+	sup.command = append([]string{exe}, args...)
+	sup.startedAt = time.Now()
+	sup.stopping = false
+	sup.exited = make(chan struct{})
+	sup.appendOutputLocked(fmt.Sprintf("[manager] 已启动: %s", strings.Join(sup.command, " ")))
+
+	go sup.capture(s, pr)
+	go sup.waitExit(cmd, sup.exited)
+	return nil
+}
+
+// capture 读取进程输出，同时推送到控制台
+func (sup *Supervisor) capture(s *GameServer, r io.ReadCloser) {
+	defer r.Close()
+	hub := GetConsoleHub(s)
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64<<10), 1<<20)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		sup.mu.Lock()
+		sup.appendOutputLocked(line)
+		sup.mu.Unlock()
+		hub.Publish(ConsoleSourceProcess, line)
+	}
+}
+
+func (sup *Supervisor) appendOutputLocked(line string) {
+	sup.output = append(sup.output, line)
+	if len(sup.output) > processOutputLines {
+		sup.output = sup.output[len(sup.output)-processOutputLines:]
+	}
+}
+
+// waitExit 进程退出后判断是否崩溃，需要时按退避时间自动重启
+func (sup *Supervisor) waitExit(cmd *exec.Cmd, exited chan struct{}) {
+	err := cmd.Wait()
+	now := time.Now()
+
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+	defer close(exited)
+
+	exit := &ProcessExit{
+		Time:    now,
+		Code:    -1,
+		Crashed: !sup.stopping,
+		Uptime:  int64(now.Sub(sup.startedAt).Seconds()),
+	}
+	if cmd.ProcessState != nil {
+		exit.Code = cmd.ProcessState.ExitCode()
+	}
+	if err != nil {
+		exit.Error = err.Error()
+	}
+	sup.cmd = nil
+	sup.lastExit = exit
+
+	if sup.stopping {
+		sup.state = ProcessStopped
+		sup.appendOutputLocked("[manager] 已停止")
+		return
+	}
+
+	if now.Sub(sup.startedAt) > processStableAfter {
+		sup.crashes = 0
+	}
+	sup.crashes++
+	exit.Restarts = sup.crashes
+	sup.appendOutputLocked(fmt.Sprintf("[manager] 进程异常退出 (code %d)", exit.Code))
+	log.Printf("服务器 %s 进程异常退出: code=%d err=%v", sup.serverID, exit.Code, err)
+
+	s, serr := GetServer(sup.serverID)
+	if serr != nil || !s.Supervised() || !s.Launch.AutoRestart {
+		sup.state = ProcessStopped
+		return
+	}
+
+	// 5s、10s、20s... 最长 5 分钟
+	backoff := processMinBackoff << (sup.crashes - 1)
+	if backoff > processMaxBackoff || backoff <= 0 {
+		backoff = processMaxBackoff
+	}
+	sup.state = ProcessBackoff
+	sup.nextRestart = now.Add(backoff)
+	sup.cancelRetry = make(chan struct{})
+	sup.appendOutputLocked(fmt.Sprintf("[manager] %s 后自动重启", backoff))
+	go sup.retryAfter(backoff, sup.cancelRetry)
+}
+
+func (sup *Supervisor) retryAfter(d time.Duration, cancel chan struct{}) {
+	select {
+	case <-cancel:
+		return
+	case <-time.After(d):
+	}
+
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+	select {
+	case <-cancel:
+		return
+	default:
+	}
+	sup.cancelRetry = nil
+	if err := sup.startLocked(); err != nil {
+		sup.appendOutputLocked("[manager] 自动重启失败: " + err.Error())
+		log.Printf("服务器 %s 自动重启失败: %v", sup.serverID, err)
+	}
+}
+
+// Stop 停止进程，先尝试正常退出，超时后强制结束
+func (sup *Supervisor) Stop() error {
+	sup.mu.Lock()
+	if sup.cancelRetry != nil {
+		close(sup.cancelRetry)
+		sup.cancelRetry = nil
+		sup.state = ProcessStopped
+	}
+	if sup.cmd == nil {
+		sup.mu.Unlock()
+		return nil
+	}
+	cmd, exited := sup.cmd, sup.exited
+	sup.stopping = true
+	sup.state = ProcessStopping
+	sup.crashes = 0
+	sup.mu.Unlock()
+
+	if err := terminateProcess(cmd); err != nil {
+		killProcess(cmd)
+	}
+	select {
+	case <-exited:
+	case <-time.After(processStopTimeout):
+		killProcess(cmd)
+		<-exited
+	}
+	return nil
+}
+
+// Restart 停止后重新启动
+func (sup *Supervisor) Restart() error {
+	if err := sup.Stop(); err != nil {
+		return err
+	}
+	return sup.Start()
+}
+
+// Status 返回当前状态和最近的输出
+func (sup *Supervisor) Status() ProcessStatus {
+	sup.mu.Lock()
+	defer sup.mu.Unlock()
+
+	status := ProcessStatus{
+		State:    sup.state,
+		Crashes:  sup.crashes,
+		LastExit: sup.lastExit,
+		Output:   append([]string{}, sup.output...),
+	}
+	if sup.cmd != nil && sup.cmd.Process != nil {
+		startedAt := sup.startedAt
+		status.PID = sup.cmd.Process.Pid
+		status.Command = sup.command
+		status.StartedAt = &startedAt
+	}
+	if sup.state == ProcessBackoff {
+		nextRestart := sup.nextRestart
+		status.NextRestart = &nextRestart
+	}
+	return status
+}
@@ -0,0 +1,21 @@
+//go:build !windows
+
+package logic
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup srcds_run 会再启动 srcds_linux，放在独立的进程组中才能一起结束
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+func terminateProcess(cmd *exec.Cmd) error {
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGTERM)
+}
+
+func killProcess(cmd *exec.Cmd) {
+	syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
@@ -0,0 +1,17 @@
+package logic
+
+import (
+	"os/exec"
+	"strconv"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// terminateProcess Windows 没有 SIGTERM，用 taskkill 结束整个进程树
+func terminateProcess(cmd *exec.Cmd) error {
+	return exec.Command("taskkill", "/T", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
+
+func killProcess(cmd *exec.Cmd) {
+	exec.Command("taskkill", "/T", "/F", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
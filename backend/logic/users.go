@@ -0,0 +1,352 @@
+package logic
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	UsersFilePath = "users.json"
+
+	RoleOwner    = "owner"
+	RoleAdmin    = "admin"
+	RoleOperator = "operator"
+	RoleViewer   = "viewer"
+	RoleGuest    = "guest"
+
+	SessionTTL = 7 * 24 * time.Hour
+)
+
+// 角色等级，数值越大权限越高
+var roleRanks = map[string]int{
+	RoleGuest:    0,
+	RoleViewer:   1,
+	RoleOperator: 2,
+	RoleAdmin:    3,
+	RoleOwner:    4,
+}
+
+var usernameRegex = regexp.MustCompile(`^[a-zA-Z0-9_.-]{1,32}$`)
+
+type User struct {
+	Username          string    `json:"username"`
+	PasswordHash      string    `json:"password_hash"`
+	Role              string    `json:"role"`
+	CreatedAt         time.Time `json:"created_at"`
+	PasswordChangedAt time.Time `json:"password_changed_at"`
+	// SessionVersion 创建用户和修改密码时更新，与令牌中的版本不一致的会话失效
+	SessionVersion int64 `json:"session_version"`
+}
+
+// UserInfo 返回给前端的用户信息，不包含密码哈希
+type UserInfo struct {
+	Username  string    `json:"username"`
+	Role      string    `json:"role"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SessionClaims 登录后签发的会话令牌
+type SessionClaims struct {
+	Username string `json:"username"`
+	Version  int64  `json:"ver"`
+	jwt.RegisteredClaims
+}
+
+var (
+	users      map[string]*User
+	usersMutex sync.RWMutex
+)
+
+func init() {
+	LoadUsers()
+}
+
+// DefaultUsername 未提供用户名时使用的账户，即首次启动时创建的所有者账户
+func DefaultUsername() string {
+	if name := os.Getenv("L4D2_MANAGER_USERNAME"); name != "" {
+		return name
+	}
+	return "admin"
+}
+
+// LoadUsers 读取用户文件，只读不写，所有者账户由 EnsureOwner 在启动时创建
+func LoadUsers() {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	users = make(map[string]*User)
+	if data, err := os.ReadFile(UsersFilePath); err == nil {
+		var list []*User
+		if err := json.Unmarshal(data, &list); err == nil {
+			for _, u := range list {
+				users[u.Username] = u
+			}
+		}
+	}
+}
+
+// EnsureOwner 没有任何用户时用 L4D2_MANAGER_PASSWORD 创建所有者账户并写入用户文件
+func EnsureOwner() error {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+
+	if len(users) > 0 {
+		if os.Getenv("L4D2_MANAGER_PASSWORD") != "" {
+			log.Printf("已存在 %s，L4D2_MANAGER_PASSWORD 不再生效，请在面板中修改密码", UsersFilePath)
+		}
+		return nil
+	}
+
+	password := os.Getenv("L4D2_MANAGER_PASSWORD")
+	if password == "" {
+		password = "laoyutangnb"
+	}
+	owner, err := newUser(DefaultUsername(), password, RoleOwner)
+	if err != nil {
+		return err
+	}
+	users[owner.Username] = owner
+	return saveUsers()
+}
+
+func saveUsers() error {
+	list := make([]*User, 0, len(users))
+	for _, u := range users {
+		list = append(list, u)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(UsersFilePath, data, 0600)
+}
+
+func newUser(username, password, role string) (*User, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	return &User{
+		Username:          username,
+		PasswordHash:      string(hash),
+		Role:              role,
+		CreatedAt:         now,
+		PasswordChangedAt: now,
+		SessionVersion:    now.UnixNano(),
+	}, nil
+}
+
+// IsValidRole 是否为可分配给用户的角色
+func IsValidRole(role string) bool {
+	_, ok := roleRanks[role]
+	return ok && role != RoleGuest
+}
+
+// CanManageRole 操作者只能管理比自己等级低的角色，所有者可以管理所有角色
+func CanManageRole(actorRole, targetRole string) bool {
+	if actorRole == RoleOwner {
+		return true
+	}
+	return roleRanks[actorRole] > roleRanks[targetRole]
+}
+
+// Authenticate 校验用户名和密码
+func Authenticate(username, password string) (*User, error) {
+	usersMutex.RLock()
+	u, ok := users[username]
+	usersMutex.RUnlock()
+
+	if !ok {
+		return nil, fmt.Errorf("用户名或密码错误")
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.PasswordHash), []byte(password)); err != nil {
+		return nil, fmt.Errorf("用户名或密码错误")
+	}
+	copied := *u
+	return &copied, nil
+}
+
+// GetUser 根据用户名获取用户
+func GetUser(username string) (*User, bool) {
+	usersMutex.RLock()
+	defer usersMutex.RUnlock()
+	u, ok := users[username]
+	if !ok {
+		return nil, false
+	}
+	copied := *u
+	return &copied, true
+}
+
+// IssueSessionToken 为用户签发会话令牌
+func IssueSessionToken(privateKey []byte, username string) (string, time.Time, error) {
+	u, ok := GetUser(username)
+	if !ok {
+		return "", time.Time{}, fmt.Errorf("用户不存在")
+	}
+	now := time.Now()
+	expiresAt := now.Add(SessionTTL)
+	claims := SessionClaims{
+		Username: username,
+		Version:  u.SessionVersion,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   username,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(privateKey)
+	return token, expiresAt, err
+}
+
+// ValidateSession 校验会话对应的用户仍然存在，且令牌签发后未修改过密码
+func ValidateSession(claims *SessionClaims) (*User, error) {
+	u, ok := GetUser(claims.Username)
+	if !ok {
+		return nil, fmt.Errorf("用户不存在")
+	}
+	// 令牌的签发时间只精确到秒，用版本号判断同一秒内修改密码的情况
+	if claims.IssuedAt == nil || claims.Version != u.SessionVersion {
+		return nil, fmt.Errorf("密码已修改，请重新登录")
+	}
+	return u, nil
+}
+
+func ListUsers() []UserInfo {
+	usersMutex.RLock()
+	defer usersMutex.RUnlock()
+
+	list := make([]UserInfo, 0, len(users))
+	for _, u := range users {
+		list = append(list, UserInfo{
+			Username:  u.Username,
+			Role:      u.Role,
+			CreatedAt: u.CreatedAt,
+		})
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+func AddUser(username, password, role string) error {
+	if !usernameRegex.MatchString(username) {
+		return fmt.Errorf("用户名只能包含字母、数字、下划线、点和连字符")
+	}
+	if !IsValidRole(role) {
+		return fmt.Errorf("无效的角色")
+	}
+	if len(password) < 6 {
+		return fmt.Errorf("密码长度不能少于6位")
+	}
+
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+	if _, ok := users[username]; ok {
+		return fmt.Errorf("用户 %s 已存在", username)
+	}
+
+	u, err := newUser(username, password, role)
+	if err != nil {
+		return err
+	}
+	users[username] = u
+	return saveUsers()
+}
+
+// UpdateUser 修改用户角色，password 非空时同时重置密码
+func UpdateUser(username, role, password string) error {
+	if !IsValidRole(role) {
+		return fmt.Errorf("无效的角色")
+	}
+	if password != "" && len(password) < 6 {
+		return fmt.Errorf("密码长度不能少于6位")
+	}
+
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+	u, ok := users[username]
+	if !ok {
+		return fmt.Errorf("用户 %s 不存在", username)
+	}
+	if u.Role == RoleOwner && role != RoleOwner && countOwners() <= 1 {
+		return fmt.Errorf("至少需要保留一个所有者")
+	}
+
+	updated := *u
+	updated.Role = role
+	if password != "" {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+		updated.PasswordHash = string(hash)
+		updated.PasswordChangedAt = time.Now()
+		updated.SessionVersion = updated.PasswordChangedAt.UnixNano()
+	}
+	users[username] = &updated
+	return saveUsers()
+}
+
+func DeleteUser(username string) error {
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+	u, ok := users[username]
+	if !ok {
+		return fmt.Errorf("用户 %s 不存在", username)
+	}
+	if u.Role == RoleOwner && countOwners() <= 1 {
+		return fmt.Errorf("至少需要保留一个所有者")
+	}
+	delete(users, username)
+	return saveUsers()
+}
+
+// ChangePassword 用户修改自己的密码，需要校验旧密码
+func ChangePassword(username, oldPassword, newPassword string) error {
+	if len(newPassword) < 6 {
+		return fmt.Errorf("密码长度不能少于6位")
+	}
+	if _, err := Authenticate(username, oldPassword); err != nil {
+		return fmt.Errorf("原密码错误")
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	usersMutex.Lock()
+	defer usersMutex.Unlock()
+	u, ok := users[username]
+	if !ok {
+		return fmt.Errorf("用户 %s 不存在", username)
+	}
+	updated := *u
+	updated.PasswordHash = string(hash)
+	updated.PasswordChangedAt = time.Now()
+	updated.SessionVersion = updated.PasswordChangedAt.UnixNano()
+	users[username] = &updated
+	return saveUsers()
+}
+
+func countOwners() int {
+	count := 0
+	for _, u := range users {
+		if u.Role == RoleOwner {
+			count++
+		}
+	}
+	return count
+}
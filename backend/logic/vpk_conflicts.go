@@ -0,0 +1,239 @@
+package logic
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	VpkConflictMap      = "map"      // 相同的地图代码
+	VpkConflictMission  = "mission"  // 相同的任务文件
+	VpkConflictCampaign = "campaign" // 相同的战役标题，章节列表中只会显示第一个
+	VpkConflictModel    = "model"
+	VpkConflictSound    = "sound"
+
+	// 每组冲突最多返回的文件路径数量，模型和音效冲突可能有上千个文件
+	maxVpkConflictPaths = 20
+)
+
+// 冲突类型按严重程度排序
+var vpkConflictOrder = map[string]int{
+	VpkConflictMap:      0,
+	VpkConflictMission:  1,
+	VpkConflictCampaign: 2,
+	VpkConflictModel:    3,
+	VpkConflictSound:    4,
+}
+
+var vpkConflictNames = map[string]string{
+	VpkConflictMap:      "地图",
+	VpkConflictMission:  "任务文件",
+	VpkConflictCampaign: "战役标题",
+	VpkConflictModel:    "模型",
+	VpkConflictSound:    "音效",
+}
+
+// VpkConflict 多个 VPK 包含相同的文件，加载顺序靠后的会覆盖前面的
+type VpkConflict struct {
+	Type  string   `json:"type"`
+	Vpks  []string `json:"vpks"`
+	Paths []string `json:"paths"` // 冲突的文件路径或地图代码，最多 maxVpkConflictPaths 个
+	Count int      `json:"count"` // 冲突的总数
+}
+
+// VpkDuplicate 内容完全相同的 VPK
+type VpkDuplicate struct {
+	Hash string   `json:"hash"`
+	Vpks []string `json:"vpks"`
+}
+
+// VpkConflictReport addons 目录的冲突检查结果
+type VpkConflictReport struct {
+	VpkCount   int            `json:"vpk_count"`
+	Conflicts  []VpkConflict  `json:"conflicts"`
+	Duplicates []VpkDuplicate `json:"duplicates"`
+	CheckedAt  time.Time      `json:"checked_at"`
+}
+
+// VpkFileList VPK 中的所有文件
+type VpkFileList struct {
+	FileName string   `json:"file_name"`
+	Files    []string `json:"files"`
+}
+
+// ListVpkFiles 列出 addons 目录中指定 VPK 的所有文件，路径统一为小写
+func ListVpkFiles(s *GameServer, fileName string) (*VpkFileList, error) {
+	if fileName == "" || fileName != path.Base(fileName) || !strings.EqualFold(path.Ext(fileName), ".vpk") {
+		return nil, fmt.Errorf("无效的文件名: %s", fileName)
+	}
+	result := loadVpkInfos(s, []string{fileName})[fileName]
+	if result == nil {
+		return nil, fmt.Errorf("文件 %s 不存在", fileName)
+	}
+	files := append([]string{}, result.files...)
+	sort.Strings(files)
+	return &VpkFileList{FileName: fileName, Files: files}, nil
+}
+
+// classifyVpkPath 返回文件的冲突类型和用于比较的键，不参与冲突检查的文件返回空字符串
+func classifyVpkPath(name string) (string, string) {
+	switch {
+	case strings.HasPrefix(name, "maps/") && strings.HasSuffix(name, ".bsp"):
+		return VpkConflictMap, strings.TrimSuffix(path.Base(name), ".bsp")
+	case strings.HasPrefix(name, "missions/") && strings.HasSuffix(name, ".txt"):
+		return VpkConflictMission, name
+	case strings.HasPrefix(name, "models/"):
+		return VpkConflictModel, name
+	case strings.HasPrefix(name, "sound/"):
+		return VpkConflictSound, name
+	}
+	return "", ""
+}
+
+// AnalyzeVpkConflicts 检查 addons 目录中所有 VPK 的文件冲突和重复
+// 内容完全相同的 VPK 只报告为重复，冲突检查时只保留其中一个
+func AnalyzeVpkConflicts(s *GameServer) (*VpkConflictReport, error) {
+	entries, err := os.ReadDir(s.AddonsPath())
+	if err != nil {
+		return nil, fmt.Errorf("读取目录失败: %v", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(path.Ext(e.Name()), ".vpk") {
+			names = append(names, e.Name())
+		}
+	}
+
+	// 地图索引中已经计算了哈希，未变化的文件不会重新计算
+	index, err := RefreshMapIndex(s)
+	if err != nil {
+		return nil, err
+	}
+	byHash := make(map[string][]string)
+	for _, entry := range index {
+		if entry.Hash != "" {
+			byHash[entry.Hash] = append(byHash[entry.Hash], entry.FileName)
+		}
+	}
+	report := &VpkConflictReport{
+		VpkCount:   len(names),
+		Conflicts:  []VpkConflict{},
+		Duplicates: []VpkDuplicate{},
+		CheckedAt:  time.Now(),
+	}
+	skip := make(map[string]bool)
+	for hash, files := range byHash {
+		if len(files) < 2 {
+			continue
+		}
+		sort.Strings(files)
+		for _, name := range files[1:] {
+			skip[name] = true
+		}
+		report.Duplicates = append(report.Duplicates, VpkDuplicate{Hash: hash, Vpks: files})
+	}
+	sort.Slice(report.Duplicates, func(i, j int) bool {
+		return report.Duplicates[i].Vpks[0] < report.Duplicates[j].Vpks[0]
+	})
+
+	// 类型 -> 键 -> 包含该文件的 VPK
+	owners := make(map[string]map[string][]string)
+	addOwner := func(kind, key, name string) {
+		if owners[kind] == nil {
+			owners[kind] = make(map[string][]string)
+		}
+		vpks := owners[kind][key]
+		if len(vpks) > 0 && vpks[len(vpks)-1] == name {
+			return
+		}
+		owners[kind][key] = append(vpks, name)
+	}
+	infos := loadVpkInfos(s, names)
+	for _, name := range names {
+		info := infos[name]
+		if info == nil || skip[name] {
+			continue
+		}
+		for _, file := range info.files {
+			if kind, key := classifyVpkPath(file); kind != "" {
+				addOwner(kind, key, name)
+			}
+		}
+		if info.campaign != nil && info.campaign.Title != "" {
+			addOwner(VpkConflictCampaign, info.campaign.Title, name)
+		}
+	}
+
+	// 同一组 VPK 的同类冲突合并为一条
+	groups := make(map[string]*VpkConflict)
+	for kind, keys := range owners {
+		for key, vpks := range keys {
+			if len(vpks) < 2 {
+				continue
+			}
+			groupKey := kind + "\x00" + strings.Join(vpks, "\x00")
+			group := groups[groupKey]
+			if group == nil {
+				group = &VpkConflict{Type: kind, Vpks: vpks, Paths: []string{}}
+				groups[groupKey] = group
+			}
+			group.Paths = append(group.Paths, key)
+			group.Count++
+		}
+	}
+	for _, group := range groups {
+		sort.Strings(group.Paths)
+		if len(group.Paths) > maxVpkConflictPaths {
+			group.Paths = group.Paths[:maxVpkConflictPaths]
+		}
+		report.Conflicts = append(report.Conflicts, *group)
+	}
+	sort.Slice(report.Conflicts, func(i, j int) bool {
+		a, b := report.Conflicts[i], report.Conflicts[j]
+		if a.Type != b.Type {
+			return vpkConflictOrder[a.Type] < vpkConflictOrder[b.Type]
+		}
+		return strings.Join(a.Vpks, "\x00") < strings.Join(b.Vpks, "\x00")
+	})
+	return report, nil
+}
+
+// CheckVpkConflicts 检查指定 VPK 与 addons 目录中其他 VPK 的冲突，返回提示信息，没有冲突时返回空
+func CheckVpkConflicts(s *GameServer, names []string) ([]string, error) {
+	report, err := AnalyzeVpkConflicts(s)
+	if err != nil {
+		return nil, err
+	}
+	involved := func(vpks []string) bool {
+		for _, vpk := range vpks {
+			for _, name := range names {
+				if vpk == name {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	var warnings []string
+	for _, dup := range report.Duplicates {
+		if involved(dup.Vpks) {
+			warnings = append(warnings, fmt.Sprintf("%s 内容完全相同", strings.Join(dup.Vpks, "、")))
+		}
+	}
+	for _, conflict := range report.Conflicts {
+		if !involved(conflict.Vpks) {
+			continue
+		}
+		warning := fmt.Sprintf("%s 包含相同的%s %s", strings.Join(conflict.Vpks, "、"), vpkConflictNames[conflict.Type], conflict.Paths[0])
+		if conflict.Count > 1 {
+			warning += fmt.Sprintf(" 等 %d 项", conflict.Count)
+		}
+		warnings = append(warnings, warning)
+	}
+	return warnings, nil
+}
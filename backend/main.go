@@ -1,12 +1,11 @@
 package main
 
 import (
-	"l4d2-manager-next/consts"
 	"l4d2-manager-next/controller"
+	"l4d2-manager-next/logic"
 	"l4d2-manager-next/middlewares"
 	"net/http"
 	"os"
-	"path/filepath"
 
 	"github.com/duke-git/lancet/v2/random"
 	"github.com/gin-gonic/gin"
@@ -33,18 +32,42 @@ func main() {
 		}
 	}
 
+	// 首次启动时创建所有者账户
+	if err := logic.EnsureOwner(); err != nil {
+		panic("创建默认用户失败: " + err.Error())
+	}
+
 	// 如果maplist.txt不存在，创建一个空的
-	mapListPath := filepath.Join(consts.MapListFilePath)
-	if _, err := os.Stat(mapListPath); os.IsNotExist(err) {
-		err := os.WriteFile(mapListPath, []byte(""), 0755)
-		if err != nil {
-			panic("创建maplist.txt失败")
-		}
+	defaultServer, _ := logic.GetServer(logic.DefaultServerID)
+	if err := defaultServer.EnsureMapList(); err != nil {
+		panic("创建maplist.txt失败")
+	}
+
+	// 接收游戏服务器通过 logaddress_add 推送的日志
+	if err := logic.StartLogListener(); err != nil {
+		panic("启动日志监听失败: " + err.Error())
 	}
 
+	// 记录玩家上下线历史
+	logic.StartPlayerTracker()
+
+	// 后台轮询服务器状态，仪表盘直接读取缓存
+	logic.StartStatusPoller()
+
+	// 定时任务
+	logic.StartScheduler()
+
+	// 由管理器守护的游戏进程
+	logic.StartSupervisors()
+
+	// 预先解析所有地图的战役信息
+	logic.WarmCampaignCache()
+
 	router.MaxMultipartMemory = 1 << 25 // 限制表单内存缓存为32M
 	router.POST("/auth", middlewares.Auth(privateKey), controller.Auth)
-	router.POST("/auth/getTempAuthCode", middlewares.Auth(privateKey), controller.GetTempAuthCode)
+	router.POST("/auth/tokens/list", middlewares.Auth(privateKey), middlewares.Require(logic.PermAuthGuest), controller.ListGuestTokens)
+	router.POST("/auth/tokens/revoke", middlewares.Auth(privateKey), middlewares.Audit("auth.revoke", "id"), middlewares.Require(logic.PermAuthGuest), controller.RevokeGuestToken)
+	router.POST("/auth/getTempAuthCode", middlewares.Auth(privateKey), middlewares.Audit("auth.guest", "label"), middlewares.Require(logic.PermAuthGuest), controller.GetTempAuthCode)
 
 	// Self-service Auth (Inject privateKey without full Auth check)
 	injectKey := func(c *gin.Context) {
@@ -52,49 +75,104 @@ func main() {
 		c.Next()
 	}
 	router.POST("/self-service/status", controller.GetSelfServiceStatus)
-	router.POST("/self-service/generate", injectKey, controller.GenerateSelfServiceCode)
-	router.POST("/config/self-service", middlewares.Auth(privateKey), controller.SetSelfServiceConfig)
-
-	router.POST("/upload", middlewares.Auth(privateKey), controller.Upload)
-	router.POST("/restart", middlewares.Auth(privateKey), controller.Restart)
-	router.POST("/clear", middlewares.Auth(privateKey), controller.Clear)
-	router.POST("/list", controller.List)
-	router.POST("/remove", middlewares.Auth(privateKey), controller.Remove)
-	router.POST("/rcon/maplist", middlewares.Auth(privateKey), controller.GetRconMapList)
-	router.POST("/rcon/changemap", middlewares.Auth(privateKey), controller.ChangeMap)
-	router.POST("/rcon/getstatus", middlewares.Auth(privateKey), controller.GetStatus)
-	router.POST("/rcon/kickuser", middlewares.Auth(privateKey), controller.KickUser)
-	router.POST("/rcon/banuser", middlewares.Auth(privateKey), controller.BanUser)
-	router.POST("/rcon/changedifficulty", middlewares.Auth(privateKey), controller.ChangeDifficulty)
-	router.POST("/rcon/changegamemode", middlewares.Auth(privateKey), controller.ChangeGameMode)
-	router.POST("/download/add", middlewares.Auth(privateKey), controller.AddDownloadTask)
-	router.POST("/download/clear", middlewares.Auth(privateKey), controller.ClearTasks)
-	router.POST("/download/list", middlewares.Auth(privateKey), controller.GetDownloadTasksInfo)
-	router.POST("/download/cancel", middlewares.Auth(privateKey), controller.CancelDownloadTask)
-	router.POST("/download/restart", middlewares.Auth(privateKey), controller.RestartDownloadTask)
-	router.POST("/getUserPlaytime", middlewares.Auth(privateKey), controller.GetUserPlaytime)
-	router.POST("/monitor/status", middlewares.Auth(privateKey), controller.GetMonitorStatus)
-	router.POST("/rcon", middlewares.Auth(privateKey), controller.Rcon)
-	router.POST("/server-info/get", middlewares.Auth(privateKey), controller.GetServerInfo)
-	router.POST("/server-info/update", middlewares.Auth(privateKey), controller.UpdateServerInfo)
+	router.POST("/self-service/generate", injectKey, middlewares.Audit("selfservice.generate"), controller.GenerateSelfServiceCode)
+	router.POST("/config/self-service", middlewares.Auth(privateKey), middlewares.Audit("settings.selfservice", "enable"), middlewares.Require(logic.PermSettingsEdit), controller.SetSelfServiceConfig)
+
+	router.POST("/upload", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("maps.upload", "map"), middlewares.Require(logic.PermMapsUpload), controller.Upload)
+	router.POST("/restart", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("server.restart", "mode"), middlewares.Require(logic.PermServerRestart), controller.Restart)
+	router.POST("/restart/pending", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermStatusView), controller.GetPendingRestart)
+	router.POST("/process/status", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermStatusView), controller.GetProcessStatus)
+	router.POST("/process/start", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("server.start"), middlewares.Require(logic.PermServerRestart), controller.StartProcess)
+	router.POST("/process/stop", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("server.stop"), middlewares.Require(logic.PermServerRestart), controller.StopProcess)
+	router.POST("/launch/get", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermServerInfoView), controller.GetLaunchProfile)
+	router.POST("/launch/update", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("server.launch", "tickrate", "port", "max_players", "sv_setmax", "map", "extra_args"), middlewares.Require(logic.PermServersManage), controller.UpdateLaunchProfile)
+	router.POST("/restart/cancel", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("server.restart.cancel"), middlewares.Require(logic.PermServerRestart), controller.CancelRestart)
+	router.POST("/clear", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("maps.clear"), middlewares.Require(logic.PermMapsDelete), controller.Clear)
+	router.POST("/list", middlewares.Server(), controller.List)
+	router.POST("/maps/library", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermMapsView), controller.GetMapLibrary)
+	router.POST("/maps/conflicts", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermMapsView), controller.GetMapConflicts)
+	router.POST("/maps/files", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermMapsView), controller.GetMapFiles)
+	router.POST("/remove", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("maps.remove", "map"), middlewares.Require(logic.PermMapsDelete), controller.Remove)
+	router.POST("/rcon/maplist", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermMapsView), controller.GetRconMapList)
+	router.POST("/rcon/changemap", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("maps.change", "mapName"), middlewares.Require(logic.PermMapsChange), controller.ChangeMap)
+	router.POST("/rcon/getstatus", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermStatusView), controller.GetStatus)
+	router.POST("/rcon/kickuser", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("players.kick", "userName", "steamId", "userId"), middlewares.Require(logic.PermPlayersKick), controller.KickUser)
+	router.POST("/rcon/banuser", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("players.ban", "steamId", "userId", "ip"), middlewares.Require(logic.PermPlayersBan), controller.BanUser)
+	router.POST("/bans/list", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermPlayersView), controller.ListBans)
+	router.POST("/bans/add", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("players.ban", "steamId", "userId", "ip"), middlewares.Require(logic.PermPlayersBan), controller.BanUser)
+	router.POST("/bans/remove", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("players.unban", "target"), middlewares.Require(logic.PermPlayersBan), controller.Unban)
+	router.POST("/cvars/list", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermCvarsView), controller.ListCvars)
+	router.POST("/cvars/get", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermCvarsView), controller.GetCvar)
+	router.POST("/cvars/set", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("cvars.set", "name", "value"), middlewares.Require(logic.PermCvarsEdit), controller.SetCvar)
+	router.POST("/rcon/changedifficulty", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("game.difficulty", "difficulty"), middlewares.Require(logic.PermGameSettings), controller.ChangeDifficulty)
+	router.POST("/rcon/changegamemode", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("game.mode", "gameMode"), middlewares.Require(logic.PermGameSettings), controller.ChangeGameMode)
+	router.POST("/download/add", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("download.add", "url"), middlewares.Require(logic.PermMapsDownload), controller.AddDownloadTask)
+	router.POST("/download/clear", middlewares.Auth(privateKey), middlewares.Audit("download.clear"), middlewares.Require(logic.PermMapsDownload), controller.ClearTasks)
+	router.POST("/download/list", middlewares.Auth(privateKey), middlewares.Require(logic.PermMapsDownload), controller.GetDownloadTasksInfo)
+	router.POST("/download/cancel", middlewares.Auth(privateKey), middlewares.Audit("download.cancel", "index"), middlewares.Require(logic.PermMapsDownload), controller.CancelDownloadTask)
+	router.POST("/download/restart", middlewares.Auth(privateKey), middlewares.Audit("download.restart", "index"), middlewares.Require(logic.PermMapsDownload), controller.RestartDownloadTask)
+	router.POST("/getUserPlaytime", middlewares.Auth(privateKey), middlewares.Require(logic.PermPlayersView), controller.GetUserPlaytime)
+	router.POST("/players/search", middlewares.Auth(privateKey), middlewares.Require(logic.PermPlayersView), controller.SearchPlayers)
+	router.POST("/players/detail", middlewares.Auth(privateKey), middlewares.Require(logic.PermPlayersView), controller.GetPlayerDetail)
+	router.POST("/monitor/status", middlewares.Auth(privateKey), middlewares.Require(logic.PermMonitorView), controller.GetMonitorStatus)
+	router.POST("/rcon", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("rcon.raw", "cmd"), middlewares.Require(logic.PermRconRaw), controller.Rcon)
+	router.POST("/console/stream", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermConsoleView), controller.ConsoleStream)
+	router.POST("/server-info/get", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermServerInfoView), controller.GetServerInfo)
+	router.POST("/server-info/update", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("serverinfo.update", "hostname"), middlewares.Require(logic.PermServerInfoEdit), controller.UpdateServerInfo)
+	router.POST("/server-cfg/files", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermServerCfgEdit), controller.ListServerCfgFiles)
+	router.POST("/server-cfg/get", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Require(logic.PermServerCfgEdit), controller.GetServerCfg)
+	router.POST("/server-cfg/update", middlewares.Auth(privateKey), middlewares.Server(), middlewares.Audit("servercfg.update", "file_name", "apply"), middlewares.Require(logic.PermServerCfgEdit), controller.UpdateServerCfg)
 	router.POST("/getVersion", controller.GetVersion)
 
-	plugins := router.Group("/plugins", middlewares.Auth(privateKey))
+	plugins := router.Group("/plugins", middlewares.Auth(privateKey), middlewares.Server())
+	{
+		plugins.POST("/list", middlewares.Require(logic.PermPluginsView), controller.GetPlugins)
+		plugins.POST("/upload", middlewares.Audit("plugins.upload", "file"), middlewares.Require(logic.PermPluginsUpload), controller.UploadPlugin)
+		plugins.POST("/enable", middlewares.Audit("plugins.enable", "name"), middlewares.Require(logic.PermPluginsEnable), controller.EnablePlugin)
+		plugins.POST("/disable", middlewares.Audit("plugins.disable", "name"), middlewares.Require(logic.PermPluginsEnable), controller.DisablePlugin)
+		plugins.POST("/delete", middlewares.Audit("plugins.delete", "name"), middlewares.Require(logic.PermPluginsDelete), controller.DeletePlugin)
+		plugins.POST("/config", middlewares.Require(logic.PermPluginsView), controller.GetPluginConfig)
+		plugins.POST("/config/update", middlewares.Audit("plugins.config", "config_name"), middlewares.Require(logic.PermPluginsConfig), controller.UpdatePluginConfig)
+	}
+
+	admins := router.Group("/admins", middlewares.Auth(privateKey), middlewares.Server())
 	{
-		plugins.POST("/list", controller.GetPlugins)
-		plugins.POST("/upload", controller.UploadPlugin)
-		plugins.POST("/enable", controller.EnablePlugin)
-		plugins.POST("/disable", controller.DisablePlugin)
-		plugins.POST("/delete", controller.DeletePlugin)
-		plugins.POST("/config", controller.GetPluginConfig)
-		plugins.POST("/config/update", controller.UpdatePluginConfig)
+		admins.POST("/list", middlewares.Require(logic.PermAdminsView), controller.GetAdmins)
+		admins.POST("/add", middlewares.Audit("admins.add", "steamid"), middlewares.Require(logic.PermAdminsEdit), controller.AddAdmin)
+		admins.POST("/update", middlewares.Audit("admins.update", "steamid"), middlewares.Require(logic.PermAdminsEdit), controller.UpdateAdmin)
+		admins.POST("/delete", middlewares.Audit("admins.delete", "steamid"), middlewares.Require(logic.PermAdminsEdit), controller.DeleteAdmin)
+		admins.POST("/groups/list", middlewares.Require(logic.PermAdminsView), controller.GetAdminGroups)
+		admins.POST("/groups/save", middlewares.Audit("admins.group.save", "name"), middlewares.Require(logic.PermAdminsEdit), controller.SaveAdminGroup)
+		admins.POST("/groups/delete", middlewares.Audit("admins.group.delete", "name"), middlewares.Require(logic.PermAdminsEdit), controller.DeleteAdminGroup)
 	}
 
-	admins := router.Group("/admins", middlewares.Auth(privateKey))
+	servers := router.Group("/servers", middlewares.Auth(privateKey))
+	{
+		servers.POST("/list", middlewares.Require(logic.PermServersView), controller.ListServers)
+		servers.POST("/add", middlewares.Audit("servers.add", "id"), middlewares.Require(logic.PermServersManage), controller.AddServer)
+		servers.POST("/update", middlewares.Audit("servers.update", "id"), middlewares.Require(logic.PermServersManage), controller.UpdateServer)
+		servers.POST("/delete", middlewares.Audit("servers.delete", "id"), middlewares.Require(logic.PermServersManage), controller.DeleteServer)
+	}
+
+	users := router.Group("/users", middlewares.Auth(privateKey))
+	{
+		users.POST("/list", middlewares.Require(logic.PermUsersManage), controller.ListUsers)
+		users.POST("/add", middlewares.Audit("users.add", "username"), middlewares.Require(logic.PermUsersManage), controller.AddUser)
+		users.POST("/update", middlewares.Audit("users.update", "username"), middlewares.Require(logic.PermUsersManage), controller.UpdateUser)
+		users.POST("/delete", middlewares.Audit("users.delete", "username"), middlewares.Require(logic.PermUsersManage), controller.DeleteUser)
+		users.POST("/password", middlewares.Audit("users.password"), controller.ChangePassword)
+	}
+
+	router.POST("/audit/list", middlewares.Auth(privateKey), middlewares.Require(logic.PermAuditView), controller.ListAudit)
+
+	schedules := router.Group("/schedules", middlewares.Auth(privateKey), middlewares.Server())
 	{
-		admins.POST("/list", controller.GetAdmins)
-		admins.POST("/add", controller.AddAdmin)
-		admins.POST("/delete", controller.DeleteAdmin)
+		schedules.POST("/list", middlewares.Require(logic.PermScheduleView), controller.ListSchedules)
+		schedules.POST("/runs", middlewares.Require(logic.PermScheduleView), controller.ListScheduleRuns)
+		schedules.POST("/add", middlewares.Audit("schedule.add", "name"), middlewares.Require(logic.PermScheduleManage), controller.AddSchedule)
+		schedules.POST("/update", middlewares.Audit("schedule.update", "id"), middlewares.Require(logic.PermScheduleManage), controller.UpdateSchedule)
+		schedules.POST("/delete", middlewares.Audit("schedule.delete", "id"), middlewares.Require(logic.PermScheduleManage), controller.DeleteSchedule)
+		schedules.POST("/run", middlewares.Audit("schedule.run", "id"), middlewares.Require(logic.PermScheduleManage), controller.RunSchedule)
 	}
 
 	port := os.Getenv("L4D2_MANAGER_PORT")
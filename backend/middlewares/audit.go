@@ -0,0 +1,213 @@
+package middlewares
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"l4d2-manager-next/logic"
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	auditMaxBodySize   = 64 << 10
+	auditMaxValueLen   = 500
+	auditMaxMessageLen = 300
+)
+
+// 表单和查询参数中鉴权用的参数不记录，名称包含敏感关键字的参数记录为掩码
+var (
+	auditSkipParams     = []string{"password", "username", "serverId"}
+	auditSensitiveWords = []string{"password", "token", "secret"}
+	// 记录原始命令的参数，rcon 控制台和定时任务的 RCON 命令
+	auditCommandParams = []string{"cmd", "command"}
+)
+
+// auditWriter 记录响应内容的开头部分，用于提取结果消息
+type auditWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *auditWriter) Write(data []byte) (int, error) {
+	if remain := auditMaxMessageLen*4 - w.body.Len(); remain > 0 {
+		if len(data) > remain {
+			w.body.Write(data[:remain])
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *auditWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Audit 在请求处理完成后写入审计日志，targetKeys 按顺序取第一个非空参数作为操作对象
+// 需放在 Auth 之后、Require 之前，这样权限不足的尝试也会被记录
+func Audit(action string, targetKeys ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		params := make(map[string]string)
+
+		// JSON 请求体会被处理函数读取，这里先读出来再放回去
+		if strings.HasPrefix(c.ContentType(), "application/json") && c.Request.Body != nil {
+			body, err := io.ReadAll(io.LimitReader(c.Request.Body, auditMaxBodySize))
+			if err == nil {
+				c.Request.Body = io.NopCloser(io.MultiReader(bytes.NewReader(body), c.Request.Body))
+				var values map[string]interface{}
+				if json.Unmarshal(body, &values) == nil {
+					for key, value := range values {
+						params[key] = auditValue(maskAuditJSON(value))
+					}
+				}
+			}
+		}
+
+		writer := &auditWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		// 表单在处理函数中才被解析，处理完成后再收集
+		formParams := make(map[string]string)
+		for key, values := range c.Request.URL.Query() {
+			formParams[key] = strings.Join(values, ",")
+		}
+		for key, values := range c.Request.PostForm {
+			formParams[key] = strings.Join(values, ",")
+		}
+		if form := c.Request.MultipartForm; form != nil {
+			for key, headers := range form.File {
+				names := make([]string, 0, len(headers))
+				for _, header := range headers {
+					names = append(names, header.Filename)
+				}
+				formParams[key] = strings.Join(names, ",")
+			}
+		}
+		for _, key := range auditSkipParams {
+			delete(formParams, key)
+		}
+		for key, value := range formParams {
+			params[key] = value
+		}
+		for key, value := range params {
+			params[key] = maskAuditValue(key, value)
+		}
+
+		entry := logic.AuditEntry{
+			Actor:   c.GetString("username"),
+			Role:    c.GetString("role"),
+			IP:      c.ClientIP(),
+			Action:  action,
+			Params:  params,
+			Status:  writer.Status(),
+			Message: auditMessage(writer.body.Bytes()),
+		}
+		if entry.Actor == "" {
+			if entry.Role == logic.RoleGuest {
+				entry.Actor = logic.RoleGuest + ":" + c.GetString("guestTokenId")
+			} else {
+				entry.Actor = "anonymous"
+			}
+		}
+		if server, exists := c.Get("server"); exists {
+			entry.ServerID = server.(*logic.GameServer).ID
+		}
+		for _, key := range targetKeys {
+			if value := params[key]; value != "" {
+				entry.Target = value
+				break
+			}
+		}
+		if entry.Status < http.StatusBadRequest {
+			entry.Result = "success"
+		} else if entry.Status == http.StatusForbidden {
+			entry.Result = "denied"
+		} else {
+			entry.Result = "failed"
+		}
+
+		if err := logic.RecordAudit(entry); err != nil {
+			log.Printf("写入审计日志失败: %v", err)
+		}
+	}
+}
+
+func auditValue(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case string:
+		s = v
+	case nil:
+		s = ""
+	default:
+		data, _ := json.Marshal(v)
+		s = string(data)
+	}
+	return s
+}
+
+// maskAuditJSON 递归处理嵌套的对象和数组，例如 server.cfg 修改中的 {"set": {"rcon_password": "..."}}
+func maskAuditJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, item := range v {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				v[key] = maskAuditJSON(item)
+			default:
+				v[key] = maskAuditValue(key, auditValue(item))
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = maskAuditJSON(v[i])
+		}
+	}
+	return value
+}
+
+func maskAuditValue(key, value string) string {
+	lower := strings.ToLower(key)
+	for _, param := range auditCommandParams {
+		if lower == param {
+			return truncateAudit(logic.MaskRconCommand(value), auditMaxValueLen)
+		}
+	}
+	for _, word := range auditSensitiveWords {
+		if strings.Contains(lower, word) {
+			if value == "" {
+				return ""
+			}
+			return "******"
+		}
+	}
+	return truncateAudit(value, auditMaxValueLen)
+}
+
+// auditMessage 从响应中提取结果消息，JSON 响应优先取 error/message 字段
+func auditMessage(body []byte) string {
+	var resp map[string]interface{}
+	if json.Unmarshal(body, &resp) == nil {
+		for _, key := range []string{"error", "message", "status"} {
+			if value, ok := resp[key]; ok {
+				return truncateAudit(fmt.Sprint(value), auditMaxMessageLen)
+			}
+		}
+		return ""
+	}
+	return truncateAudit(strings.TrimSpace(string(body)), auditMaxMessageLen)
+}
+
+func truncateAudit(s string, max int) string {
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max]) + "..."
+	}
+	return s
+}
@@ -2,8 +2,8 @@ package middlewares
 
 import (
 	"fmt"
+	"l4d2-manager-next/logic"
 	"net/http"
-	"os"
 	"sync"
 	"time"
 
@@ -45,20 +45,47 @@ func Auth(privateKey []byte) gin.HandlerFunc {
 		if password == "" {
 			password = c.Query("password")
 		}
-		realPassword := os.Getenv("L4D2_MANAGER_PASSWORD")
-		if realPassword == "" {
-			realPassword = "laoyutangnb"
+		username := c.PostForm("username")
+		if username == "" {
+			username = c.Query("username")
 		}
 
 		success := false
 		role := ""
-		if password == realPassword {
-			success = true
-			role = "admin"
-			c.Set("privateKey", privateKey)
-		} else if parsedToken, err := jwt.Parse(password, getKeyfunc(privateKey)); err == nil && parsedToken.Valid {
-			success = true
-			role = "guest"
+		var permissions []string
+		if claims, ok := parseSession(password, privateKey); ok {
+			// 会话令牌，角色以用户记录为准
+			if user, err := logic.ValidateSession(claims); err == nil {
+				success = true
+				role = user.Role
+				permissions = logic.RolePermissions(role)
+				c.Set("username", user.Username)
+				c.Set("privateKey", privateKey)
+			}
+		} else if guestClaims, ok := parseGuest(password, privateKey); ok {
+			// 临时授权码，需在登记表中且未被撤销
+			if err := logic.ValidateGuestToken(guestClaims); err == nil {
+				success = true
+				role = logic.RoleGuest
+				permissions = guestClaims.Permissions
+				if len(permissions) == 0 {
+					permissions = logic.DefaultGuestPermissions
+				}
+				c.Set("guestTokenId", guestClaims.ID)
+			}
+		} else if password != "" {
+			// 用户名密码登录，未提供用户名时使用默认账户
+			if username == "" {
+				username = logic.DefaultUsername()
+			}
+			if user, err := logic.Authenticate(username, password); err == nil {
+				success = true
+				role = user.Role
+				permissions = logic.RolePermissions(role)
+				c.Set("username", user.Username)
+				c.Set("authByPassword", true)
+				c.Set("privateKey", privateKey)
+			}
 		}
 
 		if success {
@@ -66,6 +93,7 @@ func Auth(privateKey []byte) gin.HandlerFunc {
 			delete(ipAttempts, ip)
 			mutex.Unlock()
 			c.Set("role", role)
+			c.Set("permissions", permissions)
 			c.Next()
 		} else {
 			mutex.Lock()
@@ -93,6 +121,38 @@ func Auth(privateKey []byte) gin.HandlerFunc {
 	}
 }
 
+// parseSession 解析会话令牌，临时授权码不含用户名，返回 false
+func parseSession(tokenString string, privateKey []byte) (*logic.SessionClaims, bool) {
+	claims := &logic.SessionClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, getKeyfunc(privateKey))
+	if err != nil || !token.Valid || claims.Username == "" {
+		return nil, false
+	}
+	return claims, true
+}
+
+// parseGuest 解析临时授权码
+func parseGuest(tokenString string, privateKey []byte) (*logic.GuestClaims, bool) {
+	claims := &logic.GuestClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, getKeyfunc(privateKey))
+	if err != nil || !token.Valid {
+		return nil, false
+	}
+	return claims, true
+}
+
+// Require 要求当前请求拥有指定权限，需放在 Auth 之后
+func Require(perm string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !logic.HasPermission(c.GetStringSlice("permissions"), perm) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "权限不足: " + perm})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
 func getKeyfunc(privateKey []byte) jwt.Keyfunc {
 	return func(token *jwt.Token) (interface{}, error) {
 		// 校验签名方法是否匹配
@@ -0,0 +1,28 @@
+package middlewares
+
+import (
+	"l4d2-manager-next/logic"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Server 根据请求中的 serverId 参数选择要操作的服务器，未指定时使用默认服务器
+func Server() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		serverID := c.PostForm("serverId")
+		if serverID == "" {
+			serverID = c.Query("serverId")
+		}
+
+		server, err := logic.GetServer(serverID)
+		if err != nil {
+			c.String(http.StatusNotFound, err.Error())
+			c.Abort()
+			return
+		}
+
+		c.Set("server", server)
+		c.Next()
+	}
+}
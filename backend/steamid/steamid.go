@@ -0,0 +1,118 @@
+// Package steamid 解析和转换各种格式的 SteamID
+//
+// 支持的格式:
+//
+//	SteamID2   STEAM_1:1:85790159 (STEAM_0 与 STEAM_1 等价)
+//	SteamID3   [U:1:171580319] 或 U:1:171580319
+//	SteamID64  76561198131846047
+//	个人资料链接 https://steamcommunity.com/profiles/76561198131846047
+package steamid
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// base 个人账户 SteamID64 的基数 (universe=1, type=1, instance=1)
+const base uint64 = 76561197960265728
+
+// SteamID 以 SteamID64 表示的个人账户
+type SteamID uint64
+
+var (
+	steamID2Regex   = regexp.MustCompile(`^STEAM_[0-5]:([01]):(\d+)$`)
+	steamID3Regex   = regexp.MustCompile(`^\[?U:1:(\d+)\]?$`)
+	steamID64Regex  = regexp.MustCompile(`^7656119\d{10}$`)
+	profileURLRegex = regexp.MustCompile(`^(?:https?://)?(?:www\.)?steamcommunity\.com/profiles/(\d+)/?$`)
+	vanityURLRegex  = regexp.MustCompile(`^(?:https?://)?(?:www\.)?steamcommunity\.com/id/([^/]+)/?$`)
+)
+
+// Parse 解析任意支持的格式
+func Parse(s string) (SteamID, error) {
+	s = strings.TrimSpace(s)
+
+	if m := steamID2Regex.FindStringSubmatch(strings.ToUpper(s)); m != nil {
+		y, _ := strconv.ParseUint(m[1], 10, 64)
+		z, err := strconv.ParseUint(m[2], 10, 32)
+		// 账户 ID 为 Z*2+Y，需要在 32 位范围内
+		if err != nil || z > (0xFFFFFFFF-y)/2 {
+			return 0, fmt.Errorf("无效的SteamID: %s", s)
+		}
+		return FromAccountID(uint32(z*2 + y)), nil
+	}
+
+	if m := steamID3Regex.FindStringSubmatch(strings.ToUpper(s)); m != nil {
+		accountID, err := strconv.ParseUint(m[1], 10, 32)
+		if err != nil {
+			return 0, fmt.Errorf("无效的SteamID: %s", s)
+		}
+		return FromAccountID(uint32(accountID)), nil
+	}
+
+	if m := profileURLRegex.FindStringSubmatch(s); m != nil {
+		s = m[1]
+	}
+	if steamID64Regex.MatchString(s) {
+		id, err := strconv.ParseUint(s, 10, 64)
+		if err != nil || id < base || id-base > 0xFFFFFFFF {
+			return 0, fmt.Errorf("无效的SteamID: %s", s)
+		}
+		return SteamID(id), nil
+	}
+
+	if vanityURLRegex.MatchString(s) {
+		return 0, fmt.Errorf("不支持自定义个人资料链接，请使用 /profiles/ 开头的链接或 SteamID")
+	}
+	return 0, fmt.Errorf("无效的SteamID: %s", s)
+}
+
+// FromAccountID 根据账户 ID (SteamID3 中的数字) 构造
+func FromAccountID(accountID uint32) SteamID {
+	return SteamID(base + uint64(accountID))
+}
+
+// AccountID 账户 ID，即 SteamID3 中的数字
+func (id SteamID) AccountID() uint32 {
+	return uint32(uint64(id) - base)
+}
+
+// SteamID2 返回 STEAM_1:Y:Z 格式，L4D2 使用 universe 1
+func (id SteamID) SteamID2() string {
+	accountID := id.AccountID()
+	return fmt.Sprintf("STEAM_1:%d:%d", accountID%2, accountID/2)
+}
+
+// SteamID3 返回 [U:1:N] 格式
+func (id SteamID) SteamID3() string {
+	return fmt.Sprintf("[U:1:%d]", id.AccountID())
+}
+
+// SteamID64 返回十进制的 SteamID64
+func (id SteamID) SteamID64() string {
+	return strconv.FormatUint(uint64(id), 10)
+}
+
+func (id SteamID) String() string {
+	return id.SteamID64()
+}
+
+// ProfileURL 个人资料页链接
+func (id SteamID) ProfileURL() string {
+	return "https://steamcommunity.com/profiles/" + id.SteamID64()
+}
+
+// Normalize 将可解析的 SteamID 统一为 SteamID2 格式，无法解析时原样返回
+// 用于 IP、名字等非 SteamID 身份也可能出现的场景
+func Normalize(s string) string {
+	if id, err := Parse(s); err == nil {
+		return id.SteamID2()
+	}
+	return strings.TrimSpace(s)
+}
+
+// Equal 判断两个字符串是否表示同一个 SteamID，无法解析时按原文比较
+func Equal(a, b string) bool {
+	return Normalize(a) == Normalize(b)
+}
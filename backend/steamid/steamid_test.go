@@ -0,0 +1,119 @@
+package steamid
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input   string
+		want    SteamID
+		wantErr bool
+	}{
+		{input: "STEAM_1:1:85790159", want: 76561198131846047},
+		{input: "STEAM_0:1:85790159", want: 76561198131846047},
+		{input: "steam_1:1:85790159", want: 76561198131846047},
+		{input: "  STEAM_1:0:0  ", want: 76561197960265728},
+		{input: "STEAM_1:1:2147483647", want: 76561202255233023},
+		{input: "[U:1:171580319]", want: 76561198131846047},
+		{input: "U:1:171580319", want: 76561198131846047},
+		{input: "[U:1:4294967295]", want: 76561202255233023},
+		{input: "76561198131846047", want: 76561198131846047},
+		{input: "https://steamcommunity.com/profiles/76561198131846047", want: 76561198131846047},
+		{input: "steamcommunity.com/profiles/76561198131846047/", want: 76561198131846047},
+
+		{input: "", wantErr: true},
+		{input: "STEAM_ID_PENDING", wantErr: true},
+		{input: "STEAM_1:2:1", wantErr: true},
+		{input: "STEAM_1:0:2147483648", wantErr: true},
+		{input: "STEAM_1:1:4294967295", wantErr: true},
+		{input: "[U:1:4294967296]", wantErr: true},
+		{input: "[U:2:1]", wantErr: true},
+		{input: "76561197960265727", wantErr: true},
+		{input: "7656119813184604", wantErr: true},
+		{input: "https://steamcommunity.com/id/someone", wantErr: true},
+		{input: "127.0.0.1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("期望解析失败，实际得到 %d", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("解析失败: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFormats(t *testing.T) {
+	tests := []struct {
+		id        SteamID
+		accountID uint32
+		steamID2  string
+		steamID3  string
+		steamID64 string
+	}{
+		{76561197960265728, 0, "STEAM_1:0:0", "[U:1:0]", "76561197960265728"},
+		{76561197960265729, 1, "STEAM_1:1:0", "[U:1:1]", "76561197960265729"},
+		{76561198131846047, 171580319, "STEAM_1:1:85790159", "[U:1:171580319]", "76561198131846047"},
+		{76561202255233023, 4294967295, "STEAM_1:1:2147483647", "[U:1:4294967295]", "76561202255233023"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.steamID64, func(t *testing.T) {
+			if got := tt.id.AccountID(); got != tt.accountID {
+				t.Errorf("AccountID() = %d, want %d", got, tt.accountID)
+			}
+			if got := tt.id.SteamID2(); got != tt.steamID2 {
+				t.Errorf("SteamID2() = %s, want %s", got, tt.steamID2)
+			}
+			if got := tt.id.SteamID3(); got != tt.steamID3 {
+				t.Errorf("SteamID3() = %s, want %s", got, tt.steamID3)
+			}
+			if got := tt.id.SteamID64(); got != tt.steamID64 {
+				t.Errorf("SteamID64() = %s, want %s", got, tt.steamID64)
+			}
+			if got := FromAccountID(tt.accountID); got != tt.id {
+				t.Errorf("FromAccountID(%d) = %d, want %d", tt.accountID, got, tt.id)
+			}
+			// 各种格式解析后应得到同一个 SteamID
+			for _, s := range []string{tt.steamID2, tt.steamID3} {
+				if got, err := Parse(s); err != nil || got != tt.id {
+					t.Errorf("Parse(%s) = %d, %v", s, got, err)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"[U:1:171580319]", "STEAM_1:1:85790159"},
+		{"STEAM_0:1:85790159", "STEAM_1:1:85790159"},
+		{"76561198131846047", "STEAM_1:1:85790159"},
+		{" 127.0.0.1 ", "127.0.0.1"},
+		{"BOT", "BOT"},
+	}
+	for _, tt := range tests {
+		if got := Normalize(tt.input); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+
+	if !Equal("STEAM_0:1:85790159", "[U:1:171580319]") {
+		t.Error("不同格式的同一个 SteamID 应相等")
+	}
+	if Equal("STEAM_1:1:85790159", "STEAM_1:0:85790159") {
+		t.Error("不同的 SteamID 不应相等")
+	}
+}
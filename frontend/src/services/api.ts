@@ -1,4 +1,5 @@
 import { useAuthStore } from '../stores/auth';
+import { useServerStore } from '../stores/server';
 
 class ApiService {
   private getPassword() {
@@ -6,9 +7,16 @@ class ApiService {
     return authStore.password;
   }
 
+  private getServerId() {
+    const serverStore = useServerStore();
+    return serverStore.serverId;
+  }
+
   private createFormData(data?: Record<string, any>) {
     const fd = new FormData();
     fd.append('password', this.getPassword());
+    const serverId = this.getServerId();
+    if (serverId) fd.append('serverId', serverId);
     if (data) {
       Object.entries(data).forEach(([key, value]) => {
         if (value instanceof File) {
@@ -56,6 +64,8 @@ class ApiService {
   async get(url: string, params?: Record<string, any>) {
     const urlObj = new URL(url, window.location.origin);
     urlObj.searchParams.append('password', this.getPassword());
+    const serverId = this.getServerId();
+    if (serverId) urlObj.searchParams.append('serverId', serverId);
     if (params) {
       Object.entries(params).forEach(([key, value]) => {
         urlObj.searchParams.append(key, String(value));
@@ -74,6 +84,8 @@ class ApiService {
   async postJson(url: string, data: any) {
     const urlObj = new URL(url, window.location.origin);
     urlObj.searchParams.append('password', this.getPassword());
+    const serverId = this.getServerId();
+    if (serverId) urlObj.searchParams.append('serverId', serverId);
 
     const response = await fetch(urlObj.toString(), {
       method: 'POST',
@@ -88,6 +100,12 @@ class ApiService {
     return response;
   }
 
+  async listServers() {
+    const response = await this.post('/servers/list');
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
   async validatePassword() {
     const fd = new FormData();
     fd.append('password', this.getPassword());
@@ -96,10 +114,11 @@ class ApiService {
     return { success: false, message: await response.text() };
   }
 
-  async generateTempAuthCode(expiredHours: number) {
+  async generateTempAuthCode(expiredHours: number, label?: string) {
     const fd = new FormData();
     fd.append('password', this.getPassword());
     fd.append('expired', expiredHours.toString());
+    if (label) fd.append('label', label);
 
     const response = await fetch('/auth/getTempAuthCode', { method: 'POST', body: fd });
     this.handleResponseError(response.status);
@@ -107,6 +126,51 @@ class ApiService {
     return response.text();
   }
 
+  async listGuestTokens() {
+    const response = await this.post('/auth/tokens/list');
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async revokeGuestToken(id: string) {
+    const response = await this.post('/auth/tokens/revoke', { id });
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  // 订阅服务器控制台输出，返回的 AbortController 用于断开连接
+  streamConsole(onLine: (line: { time: string; source: string; text: string }) => void) {
+    const controller = new AbortController();
+    (async () => {
+      const response = await this.fetchStream('/console/stream', controller.signal);
+      const reader = response.body!.pipeThrough(new TextDecoderStream()).getReader();
+      let buffer = '';
+      for (;;) {
+        const { value, done } = await reader.read();
+        if (done) break;
+        buffer += value;
+        const events = buffer.split('\n\n');
+        buffer = events.pop() || '';
+        for (const event of events) {
+          const data = event
+            .split('\n')
+            .filter((l) => l.startsWith('data:'))
+            .map((l) => l.slice(5))
+            .join('\n');
+          if (data) onLine(JSON.parse(data));
+        }
+      }
+    })().catch(() => {});
+    return controller;
+  }
+
+  private async fetchStream(url: string, signal: AbortSignal) {
+    const response = await fetch(url, { method: 'POST', body: this.createFormData(), signal });
+    this.handleResponseError(response.status);
+    if (!response.ok) throw new Error(await response.text());
+    return response;
+  }
+
   async getStatus() {
     // Authenticated request
     const response = await this.post('/rcon/getstatus');
@@ -135,8 +199,58 @@ class ApiService {
     return mapCode;
   }
 
-  async restartServer() {
-    const response = await this.post('/restart');
+  // mode: '' restarts immediately, 'graceful' counts down first, 'when_empty' waits for no players
+  async restartServer(mode = '', countdown = 0, message = '') {
+    const response = await this.post('/restart', { mode, countdown, message });
+    if (!response.ok) throw new Error(await response.text());
+    return response.text();
+  }
+
+  async getProcessStatus() {
+    const response = await this.post('/process/status');
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async startProcess() {
+    const response = await this.post('/process/start');
+    if (!response.ok) throw new Error(await response.text());
+    return response.text();
+  }
+
+  async stopProcess() {
+    const response = await this.post('/process/stop');
+    if (!response.ok) throw new Error(await response.text());
+    return response.text();
+  }
+
+  async getLaunchProfile() {
+    const response = await this.post('/launch/get');
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async updateLaunchProfile(data: {
+    port: number;
+    tickrate: number;
+    max_players: number;
+    sv_setmax: number;
+    map: string;
+    extra_args: string;
+  }) {
+    const response = await this.postJson('/launch/update', data);
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async getPendingRestart() {
+    const response = await this.post('/restart/pending');
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async cancelRestart() {
+    const response = await this.post('/restart/cancel');
     if (!response.ok) throw new Error(await response.text());
     return response.text();
   }
@@ -199,10 +313,31 @@ class ApiService {
         const parts = line.split('$$');
         const name = parts[0] || 'unknown';
         const size = parts[1] || 'unknown';
-        return { name, size, info: line };
+        // addoninfo.txt 中的标题和作者，没有时为空
+        const title = parts[2] || '';
+        const author = parts[3] || '';
+        return { name, size, title, author, info: line };
       });
   }
 
+  async getMapLibrary() {
+    const response = await this.post('/maps/library');
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async getMapConflicts() {
+    const response = await this.post('/maps/conflicts');
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async getMapFiles(file: string) {
+    const response = await this.post('/maps/files', { file });
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
   async getRconMapList() {
     const response = await this.post('/rcon/maplist');
     if (!response.ok) throw new Error(await response.text());
@@ -212,9 +347,7 @@ class ApiService {
   async uploadMap(file: File, onProgress?: (percent: number) => void) {
     return new Promise((resolve, reject) => {
       const xhr = new XMLHttpRequest();
-      const fd = new FormData();
-      fd.append('password', this.getPassword());
-      fd.append('map', file);
+      const fd = this.createFormData({ map: file });
 
       xhr.upload.addEventListener('progress', (e) => {
         if (e.lengthComputable && onProgress) {
@@ -243,9 +376,7 @@ class ApiService {
   }
 
   async deleteMap(mapName: string) {
-    const fd = new FormData();
-    fd.append('password', this.getPassword());
-    fd.append('map', mapName);
+    const fd = this.createFormData({ map: mapName });
 
     const response = await fetch('/remove', { method: 'POST', body: fd });
     this.handleResponseError(response.status);
@@ -254,9 +385,7 @@ class ApiService {
   }
 
   async changeMap(mapName: string) {
-    const fd = new FormData();
-    fd.append('password', this.getPassword());
-    fd.append('mapName', mapName);
+    const fd = this.createFormData({ mapName });
 
     const response = await fetch('/rcon/changemap', { method: 'POST', body: fd });
     this.handleResponseError(response.status);
@@ -288,8 +417,20 @@ class ApiService {
     return response.text();
   }
 
-  async banUser(steamId: string, kick: boolean = true) {
-    const response = await this.post('/rcon/banuser', { steamId, kick });
+  async banUser(steamId: string, kick: boolean = true, minutes: number = 0, reason: string = '') {
+    const response = await this.post('/bans/add', { steamId, kick, minutes, reason });
+    if (!response.ok) throw new Error(await response.text());
+    return response.text();
+  }
+
+  async listBans() {
+    const response = await this.post('/bans/list');
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async unban(type: 'steamid' | 'ip', target: string) {
+    const response = await this.post('/bans/remove', { type, target });
     if (!response.ok) throw new Error(await response.text());
     return response.text();
   }
@@ -312,27 +453,21 @@ class ApiService {
   }
 
   async addDownloadTask(url: string) {
-    const fd = new FormData();
-    fd.append('password', this.getPassword());
-    fd.append('url', url);
+    const fd = this.createFormData({ url });
     const response = await fetch('/download/add', { method: 'POST', body: fd });
     if (!response.ok) throw new Error(await response.text());
     return response.text();
   }
 
   async restartDownloadTask(index: number) {
-    const fd = new FormData();
-    fd.append('password', this.getPassword());
-    fd.append('index', index.toString());
+    const fd = this.createFormData({ index });
     const response = await fetch('/download/restart', { method: 'POST', body: fd });
     if (!response.ok) throw new Error(await response.text());
     return response.text();
   }
 
   async cancelDownloadTask(index: number) {
-    const fd = new FormData();
-    fd.append('password', this.getPassword());
-    fd.append('index', index.toString());
+    const fd = this.createFormData({ index });
     const response = await fetch('/download/cancel', { method: 'POST', body: fd });
     if (!response.ok) throw new Error(await response.text());
     return response.text();
@@ -356,6 +491,47 @@ class ApiService {
     return response.json();
   }
 
+  async listCvars(prefix = '', commands = false) {
+    const response = await this.post('/cvars/list', { prefix, commands: String(commands) });
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async getCvar(name: string) {
+    const response = await this.post('/cvars/get', { name });
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async setCvar(name: string, value: string) {
+    const response = await this.post('/cvars/set', { name, value });
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async listServerCfgFiles() {
+    const response = await this.post('/server-cfg/files');
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async getServerCfg(file = 'server.cfg', runtime = false) {
+    const response = await this.post('/server-cfg/get', { file, runtime: String(runtime) });
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async updateServerCfg(data: {
+    file_name: string;
+    set?: Record<string, string>;
+    remove?: string[];
+    apply?: boolean;
+  }) {
+    const response = await this.postJson('/server-cfg/update', data);
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
   async getVersion() {
     const response = await this.post('/getVersion');
     if (!response.ok) throw new Error(await response.text());
@@ -368,8 +544,32 @@ class ApiService {
     return response.json();
   }
 
-  async addAdmin(steamid: string, remark: string) {
-    const response = await this.postJson('/admins/add', { steamid, remark });
+  async addAdmin(steamid: string, remark: string, options: Record<string, any> = {}) {
+    const response = await this.postJson('/admins/add', { steamid, remark, ...options });
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async updateAdmin(admin: Record<string, any>) {
+    const response = await this.postJson('/admins/update', admin);
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async getAdminGroups() {
+    const response = await this.post('/admins/groups/list');
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async saveAdminGroup(group: { name: string; flags: string; immunity: number }) {
+    const response = await this.postJson('/admins/groups/save', group);
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async deleteAdminGroup(name: string) {
+    const response = await this.postJson('/admins/groups/delete', { name });
     if (!response.ok) throw new Error(await response.text());
     return response.json();
   }
@@ -407,6 +607,43 @@ class ApiService {
     if (!response.ok) throw new Error(await response.text());
     return response.json();
   }
+
+  async listSchedules() {
+    const response = await this.post('/schedules/list');
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
+
+  async addSchedule(job: Record<string, any>) {
+    const response = await this.postJson('/schedules/add', job);
+    if (!response.ok) throw new Error((await response.json()).error);
+    return response.json();
+  }
+
+  async updateSchedule(job: Record<string, any>) {
+    const response = await this.postJson('/schedules/update', job);
+    if (!response.ok) throw new Error((await response.json()).error);
+    return response.json();
+  }
+
+  async deleteSchedule(id: string) {
+    const response = await this.post('/schedules/delete', { id });
+    if (!response.ok) throw new Error((await response.json()).error);
+    return response.json();
+  }
+
+  async runSchedule(id: string) {
+    const response = await this.post('/schedules/run', { id });
+    const data = await response.json();
+    if (!response.ok) throw new Error(data.error);
+    return data;
+  }
+
+  async listScheduleRuns(id?: string, limit?: number) {
+    const response = await this.post('/schedules/runs', { id: id || '', limit: limit || 0 });
+    if (!response.ok) throw new Error(await response.text());
+    return response.json();
+  }
 }
 
 export const api = new ApiService();
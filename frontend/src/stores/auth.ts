@@ -4,7 +4,7 @@ import { ref, computed } from 'vue';
 export const useAuthStore = defineStore('auth', () => {
   const isAuthenticated = ref(false);
   const password = ref('');
-  const role = ref<'admin' | 'guest'>('guest');
+  const role = ref<string>('guest');
 
   // Initialize from local storage
   const init = () => {
@@ -16,16 +16,17 @@ export const useAuthStore = defineStore('auth', () => {
       // Default to guest until validated, or maybe store role too?
       // Better to re-validate on refresh usually, but we can store role
       const storedRole = localStorage.getItem('server_role');
-      if (storedRole === 'admin' || storedRole === 'guest') {
+      if (storedRole) {
         role.value = storedRole;
       }
     }
   };
 
-  const login = async (pwd: string) => {
+  const login = async (pwd: string, username: string = '') => {
     try {
       const fd = new FormData();
       fd.append('password', pwd);
+      if (username) fd.append('username', username);
       const response = await fetch('/auth', {
         method: 'POST',
         body: fd,
@@ -34,10 +35,11 @@ export const useAuthStore = defineStore('auth', () => {
       if (response.ok) {
         const data = await response.json();
         isAuthenticated.value = true;
-        password.value = pwd;
+        // 使用账户密码登录时后端返回会话令牌，后续请求使用令牌
+        password.value = data.token || pwd;
         role.value = data.role || 'guest';
 
-        localStorage.setItem('server_password', pwd);
+        localStorage.setItem('server_password', password.value);
         localStorage.setItem('server_role', role.value);
         return true;
       } else {
@@ -58,7 +60,7 @@ export const useAuthStore = defineStore('auth', () => {
     window.location.reload();
   };
 
-  const isAdmin = computed(() => role.value === 'admin');
+  const isAdmin = computed(() => role.value === 'owner' || role.value === 'admin');
 
   return {
     isAuthenticated,
@@ -0,0 +1,42 @@
+import { defineStore } from 'pinia';
+import { ref } from 'vue';
+import { api } from '../services/api';
+
+export interface ServerItem {
+  id: string;
+  name: string;
+}
+
+// 当前操作的服务器，所有请求都会带上 serverId
+export const useServerStore = defineStore('server', () => {
+  const serverId = ref(localStorage.getItem('server_id') || '');
+  const servers = ref<ServerItem[]>([]);
+
+  const load = async () => {
+    try {
+      servers.value = await api.listServers();
+    } catch {
+      // 没有查看服务器列表的权限时只能操作默认服务器
+      servers.value = [];
+    }
+    if (serverId.value && !servers.value.some((s) => s.id === serverId.value)) {
+      select('');
+    }
+  };
+
+  const select = (id: string) => {
+    serverId.value = id;
+    if (id) {
+      localStorage.setItem('server_id', id);
+    } else {
+      localStorage.removeItem('server_id');
+    }
+  };
+
+  return {
+    serverId,
+    servers,
+    load,
+    select,
+  };
+});
@@ -169,7 +169,8 @@ export function parseStatus(data: any): ParsedServerStatus {
     if (users && Array.isArray(users)) {
       result.Users = {
         label: 'Online Users',
-        users: users.map((u: any) => ({
+        // Bots are included in the status API but not listed as online users
+        users: users.filter((u: any) => !(u.isBot || u.IsBot)).map((u: any) => ({
           id: u.id || u.Id,
           name: u.name || u.Name,
           steamid: u.steamid || u.SteamId,
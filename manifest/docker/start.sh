@@ -2,6 +2,13 @@
 
 echo "检查并初始化插件文件..."
 
+# 管理面板保存的启动配置，优先于容器环境变量
+LAUNCH_ENV=/l4d2/left4dead2/launch.env
+if [ -f "${LAUNCH_ENV}" ]; then
+    echo "读取启动配置 ${LAUNCH_ENV}"
+    . "${LAUNCH_ENV}"
+fi
+
 # 控制tick配置文件，环境变量L4D2_TICK
 REAL_TICK=${L4D2_TICK:-30}  # 默认值为30
 if [ "${L4D2_TICK}" = "60" ]; then
@@ -30,7 +37,24 @@ fi
 SERVER_PORT=${L4D2_PORT:-27015}
 echo "设置服务器端口为: ${SERVER_PORT}"
 
+# 最大人数、sv_setmax、初始地图和附加参数，未设置时不传
+EXTRA_ARGS=()
+if [ -n "${L4D2_MAXPLAYERS}" ]; then
+    EXTRA_ARGS+=(-maxplayers "${L4D2_MAXPLAYERS}")
+fi
+if [ -n "${L4D2_SETMAX}" ]; then
+    EXTRA_ARGS+=(+sv_setmax "${L4D2_SETMAX}")
+fi
+if [ -n "${L4D2_MAP}" ]; then
+    EXTRA_ARGS+=(+map "${L4D2_MAP}")
+fi
+# 按空白拆分，与管理器守护模式一致，不支持用引号包含空格
+if [ -n "${L4D2_SRCDS_ARGS}" ]; then
+    read -r -a SRCDS_ARGS <<< "${L4D2_SRCDS_ARGS}"
+    EXTRA_ARGS+=("${SRCDS_ARGS[@]}")
+fi
+
 echo "文件检查和初始化完成，启动服务器..."
 
 # 启动L4D2服务器
-cd /l4d2 && ./srcds_run -game left4dead2 -insecure -tickrate "${REAL_TICK}" -condebug +hostport "${SERVER_PORT}" +exec server.cfg
+cd /l4d2 && ./srcds_run -game left4dead2 -insecure -tickrate "${REAL_TICK}" -condebug +hostport "${SERVER_PORT}" +exec server.cfg "${EXTRA_ARGS[@]}"